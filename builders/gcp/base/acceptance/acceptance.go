@@ -32,9 +32,19 @@ const (
 	javaRuntime    = "google.java.runtime"
 	nodeFF         = "google.nodejs.functions-framework"
 	nodeNPM        = "google.nodejs.npm"
+	nodeNPMBuild   = "google.nodejs.npm-gcp-build"
+	nodePNPM       = "google.nodejs.pnpm"
 	nodeRuntime    = "google.nodejs.runtime"
 	nodeYarn       = "google.nodejs.yarn"
+	nodeYarnBuild  = "google.nodejs.yarn-gcp-build"
+	phpComposer    = "google.php.composer"
+	phpRuntime     = "google.php.runtime"
+	phpWebServer   = "google.php.webserver"
 	pythonFF       = "google.python.functions-framework"
 	pythonPIP      = "google.python.pip"
+	pythonPoetry   = "google.python.poetry"
 	pythonRuntime  = "google.python.runtime"
+	rubyBundle     = "google.ruby.bundle"
+	rubyEntrypoint = "google.ruby.entrypoint"
+	rubyRuntime    = "google.ruby.runtime"
 )
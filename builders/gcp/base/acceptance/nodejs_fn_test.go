@@ -101,6 +101,38 @@ func TestAcceptanceNodeJsFn(t *testing.T) {
 			MustUse:    []string{nodeNPM},
 			MustNotUse: []string{nodeYarn},
 		},
+		{
+			Name:       "function in a subdirectory",
+			App:        "with_function_source",
+			Path:       "/testFunction",
+			Env:        []string{"GOOGLE_FUNCTION_TARGET=testFunction", "GOOGLE_FUNCTION_SOURCE=fn"},
+			MustUse:    []string{nodeRuntime, nodeNPM, nodeFF},
+			MustNotUse: []string{nodeYarn, entrypoint},
+		},
+		{
+			Name:       "function as ES module with type module",
+			App:        "esm_type_module",
+			Path:       "/testFunction",
+			Env:        []string{"GOOGLE_FUNCTION_TARGET=testFunction"},
+			MustUse:    []string{nodeRuntime, nodeNPM, nodeFF},
+			MustNotUse: []string{nodeYarn, entrypoint},
+		},
+		{
+			Name:       "function as ES module in mjs file",
+			App:        "esm_mjs",
+			Path:       "/testFunction",
+			Env:        []string{"GOOGLE_FUNCTION_TARGET=testFunction"},
+			MustUse:    []string{nodeRuntime, nodeNPM, nodeFF},
+			MustNotUse: []string{nodeYarn, entrypoint},
+		},
+		{
+			Name:       "function in TypeScript",
+			App:        "typescript",
+			Path:       "/testFunction",
+			Env:        []string{"GOOGLE_FUNCTION_TARGET=testFunction"},
+			MustUse:    []string{nodeRuntime, nodeNPM, nodeFF},
+			MustNotUse: []string{nodeYarn, entrypoint},
+		},
 	}
 	for _, tc := range testCases {
 		tc := tc
@@ -131,7 +163,7 @@ func TestFailuresNodeJsFn(t *testing.T) {
 			Name:      "function fail without valid GOOGLE_FUNCTION_SOURCE",
 			App:       "no_package",
 			Env:       []string{"GOOGLE_FUNCTION_TARGET=testFunction", "GOOGLE_FUNCTION_SOURCE=sub_dir"},
-			MustMatch: "GOOGLE_FUNCTION_SOURCE is not currently supported for Node.js buildpacks",
+			MustMatch: `GOOGLE_FUNCTION_SOURCE="sub_dir" is not a directory`,
 		},
 	}
 
@@ -51,6 +51,30 @@ func TestAcceptanceNodeJs(t *testing.T) {
 			MustUse:    []string{nodeRuntime, nodeYarn},
 			MustNotUse: []string{nodeNPM},
 		},
+		{
+			Name:    "gcp-build with npm",
+			App:     "nodejs/gcp_build_npm",
+			MustUse: []string{nodeRuntime, nodeNPMBuild, nodeNPM},
+		},
+		{
+			Name:       "gcp-build with yarn",
+			App:        "nodejs/gcp_build_yarn",
+			MustUse:    []string{nodeRuntime, nodeYarnBuild, nodeYarn},
+			MustNotUse: []string{nodeNPM},
+		},
+		{
+			Name:       "no build scripts",
+			App:        "nodejs/gcp_build_npm",
+			Env:        []string{"GOOGLE_NODE_RUN_SCRIPTS=lint"},
+			MustUse:    []string{nodeRuntime, nodeNPM},
+			MustNotUse: []string{nodeNPMBuild},
+		},
+		{
+			Name:       "pnpm",
+			App:        "nodejs/pnpm_lock",
+			MustUse:    []string{nodeRuntime, nodePNPM},
+			MustNotUse: []string{nodeNPM, nodeYarn},
+		},
 		{
 			Name:       "yarn (Dev Mode)",
 			App:        "nodejs/yarn",
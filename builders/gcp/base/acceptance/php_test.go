@@ -0,0 +1,88 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/acceptance"
+)
+
+func init() {
+	acceptance.DefineFlags()
+}
+
+func TestAcceptancePHP(t *testing.T) {
+	builder, cleanup := acceptance.CreateBuilder(t)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.Test{
+		{
+			Name:       "symfony app served from public",
+			App:        "symfony",
+			MustUse:    []string{phpRuntime, phpComposer, phpWebServer},
+			MustNotUse: []string{entrypoint},
+		},
+		{
+			Name:    "composer.lock respected",
+			App:     "composer_lock",
+			MustUse: []string{phpRuntime, phpComposer, phpWebServer},
+		},
+		{
+			Name:       "built-in web server",
+			App:        "no_composer_json",
+			Env:        []string{"GOOGLE_PHP_WEBSERVER=builtin"},
+			MustUse:    []string{phpRuntime, phpWebServer},
+			MustNotUse: []string{phpComposer},
+		},
+		{
+			Name:       "entrypoint from env",
+			App:        "no_composer_json",
+			Env:        []string{"GOOGLE_ENTRYPOINT=php -S 0.0.0.0:8080 index.php"},
+			MustUse:    []string{phpRuntime, entrypoint},
+			MustNotUse: []string{phpWebServer},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			acceptance.TestApp(t, builder, tc)
+		})
+	}
+}
+
+func TestFailuresPHP(t *testing.T) {
+	builder, cleanup := acceptance.CreateBuilder(t)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.FailureTest{
+		{
+			Name:      "invalid document root",
+			App:       "no_composer_json",
+			Env:       []string{"GOOGLE_PHP_DOCUMENT_ROOT=missing"},
+			MustMatch: `GOOGLE_PHP_DOCUMENT_ROOT "missing" is not a directory`,
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			acceptance.TestBuildFailure(t, builder, tc)
+		})
+	}
+}
@@ -67,6 +67,13 @@ func TestAcceptancePython(t *testing.T) {
 			MustUse:    []string{pythonRuntime},
 			MustNotUse: []string{goRuntime, javaRuntime, nodeRuntime},
 		},
+		{
+			Name:       "poetry",
+			App:        "python/poetry",
+			Env:        []string{"GOOGLE_ENTRYPOINT=python3 main.py"},
+			MustUse:    []string{pythonRuntime, pythonPoetry, entrypoint},
+			MustNotUse: []string{pythonPIP},
+		},
 		{
 			Name:    "python with client-side scripts correctly builds as a python app",
 			App:     "python/scripts",
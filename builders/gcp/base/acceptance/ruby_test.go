@@ -0,0 +1,91 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+package acceptance
+
+import (
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/acceptance"
+)
+
+func init() {
+	acceptance.DefineFlags()
+}
+
+func TestAcceptanceRuby(t *testing.T) {
+	builder, cleanup := acceptance.CreateBuilder(t)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.Test{
+		{
+			Name:    "rack inferred entrypoint",
+			App:     "rack_inferred",
+			MustUse: []string{rubyRuntime, rubyBundle, rubyEntrypoint},
+		},
+		{
+			Name:    "rails inferred entrypoint",
+			App:     "rails_inferred",
+			MustUse: []string{rubyRuntime, rubyBundle, rubyEntrypoint},
+		},
+		{
+			Name:       "entrypoint from env",
+			App:        "simple_gemfile",
+			Env:        []string{"GOOGLE_ENTRYPOINT=bundle exec ruby myapp.rb"},
+			MustUse:    []string{rubyRuntime, rubyBundle, entrypoint},
+			MustNotUse: []string{rubyEntrypoint},
+		},
+		{
+			Name:    "runtime version from Gemfile",
+			App:     "version_specified_gemfile_27",
+			Env:     []string{"GOOGLE_ENTRYPOINT=bundle exec ruby myapp.rb"},
+			MustUse: []string{rubyRuntime, rubyBundle, entrypoint},
+		},
+		{
+			Name:    "gems.rb",
+			App:     "version_specified_gems_27",
+			Env:     []string{"GOOGLE_ENTRYPOINT=bundle exec ruby myapp.rb"},
+			MustUse: []string{rubyRuntime, rubyBundle, entrypoint},
+		},
+	}
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			acceptance.TestApp(t, builder, tc)
+		})
+	}
+}
+
+func TestFailuresRuby(t *testing.T) {
+	builder, cleanup := acceptance.CreateBuilder(t)
+	t.Cleanup(cleanup)
+
+	testCases := []acceptance.FailureTest{
+		{
+			Name:      "cannot infer entrypoint",
+			App:       "fail_cannot_infer_entrypoint",
+			MustMatch: "unable to infer the entrypoint of a Ruby application",
+		},
+	}
+
+	for _, tc := range testCases {
+		tc := tc
+		t.Run(tc.Name, func(t *testing.T) {
+			t.Parallel()
+
+			acceptance.TestBuildFailure(t, builder, tc)
+		})
+	}
+}
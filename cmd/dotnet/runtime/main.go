@@ -21,14 +21,15 @@ import (
 	"fmt"
 	"io/ioutil"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/dotnet"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/blang/semver"
 	"github.com/buildpack/libbuildpack/buildpackplan"
 	"github.com/buildpack/libbuildpack/layers"
 )
@@ -37,7 +38,6 @@ const (
 	sdkLayer     = "sdk"
 	runtimeLayer = "runtime"
 	sdkURL       = "https://dotnetcli.azureedge.net/dotnet/Sdk/%[1]s/dotnet-sdk-%[1]s-linux-x64.tar.gz"
-	versionURL   = "https://dotnetcli.azureedge.net/dotnet/Sdk/LTS/latest.version"
 )
 
 // metadata represents metadata stored for a runtime layer.
@@ -84,27 +84,53 @@ func buildFn(ctx *gcp.Context) error {
 	}
 
 	ctx.CacheMiss(sdkLayer)
-	ctx.ClearLayer(sdkl)
-
 	ctx.CacheMiss(runtimeLayer)
-	ctx.ClearLayer(rtl)
 
-	archiveURL := fmt.Sprintf(sdkURL, version)
-	if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
-		return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+	// The archive holds both the SDK and the runtime, and is downloaded at most once, when either is not cached.
+	var archive string
+	download := func() (string, error) {
+		if archive != "" {
+			return archive, nil
+		}
+		archiveURL := fmt.Sprintf(sdkURL, version)
+		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+			return "", gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		}
+		ctx.Logf("Installing .NET SDK v%s", version)
+		archive = filepath.Join(ctx.TempDir("", "dotnet-"), "sdk.tar.gz")
+		ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", "--retry", "3", "--output", archive, archiveURL})
+		return archive, nil
+	}
+	defer func() {
+		if archive != "" {
+			ctx.RemoveAll(filepath.Dir(archive))
+		}
+	}()
+	if err := runtime.InstallVersion(ctx, sdkl, version, func(dir string) error {
+		a, err := download()
+		if err != nil {
+			return err
+		}
+		ctx.Exec([]string{"tar", "xzf", a, "--directory", dir, "--strip-components=2", "./sdk"})
+		return nil
+	}); err != nil {
+		return err
+	}
+	if err := runtime.InstallVersion(ctx, rtl, version, func(dir string) error {
+		a, err := download()
+		if err != nil {
+			return err
+		}
+		ctx.Exec([]string{"tar", "xzf", a, "--directory", dir, "--strip-components=1", "--exclude=./sdk"})
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	ctx.Logf("Installing .NET SDK v%s", version)
-	// Ensure there's a symlink from runtime/sdk dir to the sdk layer.
+	// The dotnet CLI needs an sdk directory in the same directory as the dotnet executable.
 	// TODO(b/150893022): remove the symlink in the final image.
 	ctx.Exec([]string{"ln", "--symbolic", "--force", sdkl.Root, filepath.Join(rtl.Root, "sdk")})
 
-	// With --keep-directory-symlink, the SDK will be unpacked into /runtime/sdk,
-	// which is symlinked to the SDK layer. This is needed because the dotnet CLI
-	// needs an sdk directory in the same directory as the dotnet executable.
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --keep-directory-symlink --strip-components=1", archiveURL, rtl.Root)
-	ctx.Exec([]string{"bash", "-c", command})
-
 	// Keep the SDK layer for launch in devmode because we use `dotnet watch`.
 	sdkMeta.Version = version
 	if devmode.Enabled(ctx) {
@@ -127,42 +153,126 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-// globalJSON represents the contents of a global.json file.
-type globalJSON struct {
-	Sdk struct {
-		Version string `json:"version"`
-	} `json:"sdk"`
-}
-
 // runtimeVersion returns the version of the .NET Core SDK to install.
+// The version is read from env var if set, then global.json, applying its rollForward policy. Otherwise, it is the
+// latest SDK for the highest target framework of the projects, or the latest LTS version if there are no projects.
+// The selected SDK must support the target frameworks of all projects.
 func runtimeVersion(ctx *gcp.Context) (string, error) {
-	version := os.Getenv(env.RuntimeVersion)
-	if version != "" {
-		ctx.Logf("Using .NET Core SDK version from env: %s", version)
-		return version, nil
+	required, proj, err := requiredSDK(ctx)
+	if err != nil {
+		return "", err
 	}
 
-	if ctx.FileExists("global.json") {
-		rawgjs, err := ioutil.ReadFile(filepath.Join(ctx.ApplicationRoot(), "global.json"))
+	version, source, err := selectSDK(ctx, required, proj)
+	if err != nil {
+		return "", err
+	}
+
+	if proj != "" {
+		v, err := semver.ParseTolerant(version)
 		if err != nil {
-			return "", fmt.Errorf("reading global.json: %v", err)
+			return "", gcp.UserErrorf("parsing .NET Core SDK version %q: %v", version, err)
 		}
+		if v.Major < required.Major || (v.Major == required.Major && v.Minor < required.Minor) {
+			return "", gcp.UserErrorf("%s targets .NET %d.%d, which requires .NET SDK %d.%d or later, but version %s was selected from %s", proj, required.Major, required.Minor, required.Major, required.Minor, version, source)
+		}
+	}
+	return version, nil
+}
+
+// selectSDK returns the SDK version to install and where it came from.
+func selectSDK(ctx *gcp.Context, required semver.Version, proj string) (string, string, error) {
+	if c, ok := runtime.EnvConstraint(); ok {
+		v, err := runtime.ResolveVersion(ctx, runtime.DotnetSDK, c)
+		return v, env.RuntimeVersion, err
+	}
 
-		var gjs globalJSON
+	gjsPath := filepath.Join(ctx.ApplicationRoot(), "global.json")
+	if ctx.FileExists(gjsPath) {
+		rawgjs, err := ioutil.ReadFile(gjsPath)
+		if err != nil {
+			return "", "", fmt.Errorf("reading global.json: %v", err)
+		}
+
+		var gjs dotnet.GlobalJSON
 		if err := json.Unmarshal(rawgjs, &gjs); err != nil {
-			return "", gcp.UserErrorf("unmarshalling global.json: %v", err)
+			return "", "", gcp.UserErrorf("unmarshalling global.json: %v", err)
 		}
 
 		if gjs.Sdk.Version != "" {
-			ctx.Logf("Using .NET Core SDK version from global.json: %s", version)
-			return gjs.Sdk.Version, nil
+			releases, err := runtime.Releases(ctx, runtime.DotnetSDK)
+			if err != nil {
+				return "", "", err
+			}
+			var available []string
+			for _, r := range releases {
+				available = append(available, r.Version)
+			}
+			allowPrerelease := gjs.Sdk.AllowPrerelease != nil && *gjs.Sdk.AllowPrerelease
+			v, err := dotnet.SelectSDK(available, gjs.Sdk.Version, gjs.Sdk.RollForward, allowPrerelease)
+			if err != nil && unlisted(available, gjs.Sdk.Version) {
+				// The index omits end-of-life channels, whose SDKs can still be installed when requested exactly.
+				ctx.Warnf("Installing .NET Core SDK %s from global.json as is, it is not in the version index: %v", gjs.Sdk.Version, err)
+				return strings.TrimSpace(gjs.Sdk.Version), "global.json", nil
+			}
+			if err != nil {
+				return "", "", gcp.UserErrorf("selecting .NET Core SDK from global.json: %v", err)
+			}
+			ctx.Logf("Using .NET Core SDK version %s from global.json version %q, rollForward %q", v, gjs.Sdk.Version, gjs.Sdk.RollForward)
+			return v, "global.json", nil
 		}
 	}
 
-	// Use the latest LTS version.
-	command := fmt.Sprintf("curl --silent %s | tail -n 1", versionURL)
-	result := ctx.Exec([]string{"bash", "-c", command})
-	version = result.Stdout
-	ctx.Logf("Using the latest LTS version of .NET Core SDK: %s", version)
-	return version, nil
+	// Build with the SDK of the runtime the application runs on, which is included in the SDK archive.
+	c := runtime.Constraint{Value: "lts"}
+	if proj != "" {
+		c = runtime.Constraint{Value: fmt.Sprintf("%d.%d.x", required.Major, required.Minor), Source: proj}
+	}
+	v, err := runtime.ResolveVersion(ctx, runtime.DotnetSDK, c)
+	if err != nil && proj != "" {
+		// The index omits end-of-life channels, which are only listed for the SDK of an end-of-life target framework.
+		if eolv, eolErr := runtime.ResolveVersion(ctx, runtime.DotnetSDKEOL, c); eolErr == nil {
+			ctx.Warnf("%s targets .NET %d.%d, which has reached end of life.", proj, required.Major, required.Minor)
+			return eolv, c.Source, nil
+		}
+	}
+	return v, c.Source, err
+}
+
+// unlisted returns true if version is a valid SDK version that is missing from available.
+func unlisted(available []string, version string) bool {
+	version = strings.TrimSpace(version)
+	if _, err := semver.Parse(version); err != nil {
+		return false
+	}
+	for _, a := range available {
+		if a == version {
+			return false
+		}
+	}
+	return true
+}
+
+// requiredSDK returns the highest .NET version targeted by the application's projects, and the project targeting it.
+// The project is empty if no project targets .NET Core or .NET 5+.
+func requiredSDK(ctx *gcp.Context) (semver.Version, string, error) {
+	var required semver.Version
+	var proj string
+	for _, p := range dotnet.ProjectFiles(ctx, ctx.ApplicationRoot()) {
+		project, err := dotnet.ReadProjectFile(ctx, p)
+		if err != nil {
+			return semver.Version{}, "", err
+		}
+		for _, tfm := range project.TargetFrameworks() {
+			if v, ok := dotnet.TargetFrameworkVersion(tfm); ok && (proj == "" || v.GT(required)) {
+				required = v
+				rel, err := filepath.Rel(ctx.ApplicationRoot(), p)
+				if err != nil {
+					rel = p
+				}
+				proj = rel
+			}
+		}
+	}
+	return required, proj, nil
 }
@@ -15,9 +15,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -68,3 +74,128 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestRuntimeVersion(t *testing.T) {
+	defer runtime.RegisterIndex(runtime.DotnetSDK, runtime.StaticIndex{
+		{Version: "3.1.402", LTS: "lts"},
+		{Version: "3.1.401", LTS: "lts"},
+		{Version: "3.1.302", LTS: "lts"},
+		{Version: "3.1.301", LTS: "lts"},
+		{Version: "5.0.101"},
+		{Version: "5.0.100"},
+	})()
+	defer runtime.RegisterIndex(runtime.DotnetSDKEOL, runtime.StaticIndex{
+		{Version: "3.1.402", LTS: "lts"},
+		{Version: "5.0.101"},
+		{Version: "2.1.811"},
+		{Version: "2.1.810"},
+	})()
+
+	netcoreapp31 := `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>netcoreapp3.1</TargetFramework></PropertyGroup></Project>`
+	net50 := `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>net5.0</TargetFramework></PropertyGroup></Project>`
+
+	testCases := []struct {
+		name    string
+		files   map[string]string
+		want    string
+		wantErr string
+	}{
+		{
+			name:  "no projects",
+			files: map[string]string{"app.dll": ""},
+			want:  "3.1.402",
+		},
+		{
+			name:  "target framework",
+			files: map[string]string{"app.csproj": net50},
+			want:  "5.0.101",
+		},
+		{
+			name: "highest target framework",
+			files: map[string]string{
+				"app.csproj": netcoreapp31,
+				"lib.csproj": net50,
+			},
+			want: "5.0.101",
+		},
+		{
+			name:  "end-of-life target framework",
+			files: map[string]string{"app.csproj": `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>netcoreapp2.1</TargetFramework></PropertyGroup></Project>`},
+			want:  "2.1.811",
+		},
+		{
+			name:    "unknown target framework",
+			files:   map[string]string{"app.csproj": `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>netcoreapp1.0</TargetFramework></PropertyGroup></Project>`},
+			wantErr: "resolving .NET Core SDK version",
+		},
+		{
+			name: "global.json default rollForward",
+			files: map[string]string{
+				"app.csproj":  netcoreapp31,
+				"global.json": `{"sdk": {"version": "3.1.301"}}`,
+			},
+			want: "3.1.302",
+		},
+		{
+			name: "global.json rollForward",
+			files: map[string]string{
+				"app.csproj":  netcoreapp31,
+				"global.json": `{"sdk": {"version": "3.1.301", "rollForward": "latestFeature"}}`,
+			},
+			want: "3.1.402",
+		},
+		{
+			name: "global.json SDK of end-of-life channel",
+			files: map[string]string{
+				"app.csproj":  `<Project Sdk="Microsoft.NET.Sdk.Web"><PropertyGroup><TargetFramework>netcoreapp2.1</TargetFramework></PropertyGroup></Project>`,
+				"global.json": `{"sdk": {"version": "2.1.811"}}`,
+			},
+			want: "2.1.811",
+		},
+		{
+			name: "global.json invalid version",
+			files: map[string]string{
+				"app.csproj":  netcoreapp31,
+				"global.json": `{"sdk": {"version": "3.1"}}`,
+			},
+			wantErr: "selecting .NET Core SDK from global.json",
+		},
+		{
+			name: "global.json SDK too old for target framework",
+			files: map[string]string{
+				"app.csproj":  net50,
+				"global.json": `{"sdk": {"version": "3.1.301"}}`,
+			},
+			wantErr: "app.csproj targets .NET 5.0, which requires .NET SDK 5.0 or later",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for f, c := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := runtimeVersion(ctx)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Errorf("runtimeVersion() = %q, %v, want error containing %q", got, err, tc.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
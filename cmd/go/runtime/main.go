@@ -17,11 +17,8 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"net/http"
-	"os"
-	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
@@ -32,10 +29,8 @@ import (
 )
 
 const (
-	// goVersionURL is a URL to a JSON file that contains the latest Go version names.
-	goVersionURL = "https://golang.org/dl/?mode=json"
-	goURL        = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
-	goLayer      = "go"
+	goURL   = "https://dl.google.com/go/go%s.linux-amd64.tar.gz"
+	goLayer = "go"
 )
 
 // metadata represents metadata stored for a runtime layer.
@@ -69,18 +64,21 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.CacheHit(goLayer)
 	} else {
 		ctx.CacheMiss(goLayer)
-		ctx.ClearLayer(grl)
-
-		archiveURL := fmt.Sprintf(goURL, version)
-		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
-			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		if err := runtime.InstallVersion(ctx, grl, version, func(dir string) error {
+			archiveURL := fmt.Sprintf(goURL, version)
+			if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+				return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+			}
+
+			// Download and install Go in the version cache.
+			ctx.Logf("Installing Go v%s", version)
+			command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, dir)
+			ctx.Exec([]string{"bash", "-c", command})
+			return nil
+		}); err != nil {
+			return err
 		}
 
-		// Download and install Go in layer.
-		ctx.Logf("Installing Go v%s", version)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, grl.Root)
-		ctx.Exec([]string{"bash", "-c", command})
-
 		meta.Version = version
 	}
 
@@ -94,47 +92,21 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// runtimeVersion returns the version of Go to install.
+// GOOGLE_RUNTIME_VERSION may be an exact version or a range such as `1.14.x`. Otherwise, the `go` directive in go.mod
+// is a minimum which resolves to the latest patch release of that minor version, and without one the latest stable
+// release is used.
 func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+	if c, ok := runtime.EnvConstraint(); ok {
+		return runtime.ResolveVersion(ctx, runtime.Go, c)
 	}
-	if version := golang.GoModVersion(ctx); version != "" {
-		ctx.Logf("Using runtime version from go.mod: %s", version)
-		return version, nil
-	}
-	version, err := latestGoVersion(ctx)
-	if err != nil {
-		return "", fmt.Errorf("getting latest version: %w", err)
-	}
-	ctx.Logf("Using latest runtime version: %s", version)
-	return version, nil
-}
-
-type goReleases []struct {
-	Version string `json:"version"`
-	Stable  bool   `json:"stable"`
+	return runtime.ResolveVersion(ctx, runtime.Go, goModConstraint(golang.GoModVersion(ctx)))
 }
 
-// latestGoVersion returns the latest version of Go
-func latestGoVersion(ctx *gcp.Context) (string, error) {
-	result := ctx.Exec([]string{"curl", "--silent", goVersionURL})
-	return parseVersionJSON(result.Stdout)
-}
-
-func parseVersionJSON(jsonStr string) (string, error) {
-	releases := goReleases{}
-	if err := json.Unmarshal([]byte(jsonStr), &releases); err != nil {
-		return "", fmt.Errorf("parsing JSON response from URL %q: %v", goVersionURL, err)
-	}
-
-	for _, release := range releases {
-		if !release.Stable {
-			continue
-		}
-		if v := strings.TrimPrefix(release.Version, "go"); v != "" {
-			return v, nil
-		}
+// goModConstraint returns the constraint for a go.mod `go` directive, e.g. `~1.14` for `go 1.14`.
+func goModConstraint(v string) runtime.Constraint {
+	if v == "" {
+		return runtime.Constraint{}
 	}
-	return "", fmt.Errorf("parsing latest stable version from %q", goVersionURL)
+	return runtime.Constraint{Value: "~" + v, Source: "go.mod"}
 }
@@ -15,9 +15,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -85,50 +91,78 @@ func TestDetect(t *testing.T) {
 	}
 }
 
-func TestJSONVersionParse(t *testing.T) {
+func TestRuntimeVersion(t *testing.T) {
+	defer runtime.RegisterIndex(runtime.Go, runtime.StaticIndex{
+		{Version: "1.15.2"},
+		{Version: "1.15"},
+		{Version: "1.14.9"},
+		{Version: "1.14.1"},
+		{Version: "1.14"},
+		{Version: "1.13.15"},
+	})()
+
 	testCases := []struct {
-		name string
-		want string
-		json string
+		name       string
+		gomod      string
+		envVersion string
+		want       string
 	}{
 		{
-			name: "all_stable",
-			want: "1.13.3",
-			json: `
-[
- {
-  "version": "go1.13.3",
-  "stable": true
- },
- {
-  "version": "go1.12.12",
-  "stable": true
- }
-]`,
+			name: "latest without go.mod",
+			want: "1.15.2",
+		},
+		{
+			name:  "go directive resolves to latest patch",
+			gomod: "module app\n\ngo 1.14\n",
+			want:  "1.14.9",
+		},
+		{
+			name:  "go directive with patch",
+			gomod: "module app\n\ngo 1.13.1\n",
+			want:  "1.13.15",
+		},
+		{
+			name:       "env range",
+			gomod:      "module app\n\ngo 1.13\n",
+			envVersion: "1.14.x",
+			want:       "1.14.9",
 		},
 		{
-			name: "recent_unstable",
-			want: "1.12.12",
-			json: `
-[
- {
-  "version": "go1.13.3",
-  "stable": false
- },
- {
-  "version": "go1.12.12",
-  "stable": true
- }
-]`,
+			name:       "env exact release",
+			envVersion: "1.14",
+			want:       "1.14",
 		},
 	}
-
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			if v, err := parseVersionJSON(tc.json); err != nil {
-				t.Fatalf("parseVersionJSON() failed: %v", tc.name, err)
-			} else if v != tc.want {
-				t.Errorf("parseVersionJSON() = %q, want %q", tc.name, v, tc.want)
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if tc.gomod != "" {
+				if err := ioutil.WriteFile(filepath.Join(dir, "go.mod"), []byte(tc.gomod), 0644); err != nil {
+					t.Fatalf("writing go.mod: %v", err)
+				}
+			}
+			if tc.envVersion != "" {
+				if err := os.Setenv(env.RuntimeVersion, tc.envVersion); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func() {
+					if err := os.Unsetenv(env.RuntimeVersion); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}()
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := runtimeVersion(ctx)
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion() = %q, want %q", got, tc.want)
 			}
 		})
 	}
@@ -17,11 +17,16 @@ package main
 
 import (
 	"fmt"
+	"os"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 )
 
+// jlinkLayer holds the minimal Java runtime created with jlink when GOOGLE_JAVA_JLINK is set.
+const jlinkLayer = "jlink"
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -37,6 +42,16 @@ func buildFn(ctx *gcp.Context) error {
 		return fmt.Errorf("finding executable jar: %w", err)
 	}
 
+	if java.JlinkEnabled(ctx) {
+		javaHome := os.Getenv("JAVA_HOME")
+		// The java/runtime buildpack installs a JRE for launch unless the JDK includes jlink, e.g. for Java 8.
+		if v := java.JDKFeatureVersion(ctx, javaHome); v == "" {
+			ctx.Warnf("Ignoring %s, no JDK with jlink was found at JAVA_HOME=%q.", env.JavaJlink, javaHome)
+		} else {
+			java.Jlink(ctx, ctx.Layer(jlinkLayer), javaHome, v, executable)
+		}
+	}
+
 	ctx.AddWebProcess([]string{"java", "-jar", executable})
 	return nil
 }
@@ -56,18 +56,25 @@ func detectFn(ctx *gcp.Context) error {
 func buildFn(ctx *gcp.Context) error {
 	layer := ctx.Layer(layerName)
 
-	if err := installFunctionsFramework(ctx, layer); err != nil {
-		return err
-	}
-
-	classpath, err := classpath(ctx)
+	// Fetching the framework does not depend on resolving the function's dependencies, so do both at once.
+	var cp string
+	err := ctx.RunConcurrently(
+		gcp.Task{Name: "Install functions framework", Fn: func(c *gcp.Context) error {
+			return installFunctionsFramework(c, layer)
+		}},
+		gcp.Task{Name: "Determine function classpath", Fn: func(c *gcp.Context) error {
+			var err error
+			cp, err = classpath(c)
+			return err
+		}},
+	)
 	if err != nil {
 		return err
 	}
 
 	ctx.SetFunctionsEnvVars(layer)
 
-	ctx.AddWebProcess([]string{"java", "-jar", filepath.Join(layer.Root, "functions-framework.jar"), "--classpath", classpath})
+	ctx.AddWebProcess([]string{"java", "-jar", filepath.Join(layer.Root, "functions-framework.jar"), "--classpath", cp})
 
 	return nil
 }
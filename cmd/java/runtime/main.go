@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // Implements java/runtime buildpack.
-// The runtime buildpack installs the JDK.
+// The runtime buildpack installs the JDK for build and a JRE for launch.
 package main
 
 import (
@@ -21,17 +21,25 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/java"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
 	"github.com/buildpack/libbuildpack/buildpackplan"
 	"github.com/buildpack/libbuildpack/layers"
 )
 
 const (
+	// javaLayer is the layer that held both the JDK and the launch runtime in earlier versions of this buildpack.
 	javaLayer             = "java"
-	javaVersionURL        = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=jdk&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
+	javaPlan              = "openjdk"
+	jdkLayer              = "jdk"
+	jreLayer              = "jre"
+	jdkImage              = "jdk"
+	jreImage              = "jre"
+	javaVersionURL        = "https://api.adoptopenjdk.net/v3/assets/feature_releases/%s/ga?architecture=x64&heap_size=normal&image_type=%s&jvm_impl=hotspot&os=linux&page=0&page_size=1&project=jdk&sort_order=DESC&vendor=adoptopenjdk"
 	defaultFeatureVersion = "11"
 )
 
@@ -59,55 +67,127 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	featureVersion := defaultFeatureVersion
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		featureVersion = v
-		ctx.Logf("Using requested runtime feature version: %s", featureVersion)
+	featureVersion, err := runtimeFeatureVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Clear the layer from earlier builds so that it is neither restored from the cache nor exported.
+	jl := ctx.Layer(javaLayer)
+	ctx.ClearLayer(jl)
+	ctx.WriteMetadata(jl, nil)
+
+	// The JDK is only needed to build the application; a smaller runtime is installed for launch.
+	jdk, version, err := install(ctx, jdkLayer, jdkImage, featureVersion, layers.Build, layers.Cache)
+	if err != nil {
+		return err
+	}
+	ctx.OverrideBuildEnv(jdk, "JAVA_HOME", jdk.Root)
+
+	if useJlink(ctx, featureVersion, jdk.Root) {
+		ctx.Logf("Skipping JRE installation, the java/entrypoint buildpack creates a minimal runtime with jlink.")
 	} else {
-		ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
+		jre, _, err := install(ctx, jreLayer, jreImage, featureVersion, layers.Launch, layers.Cache)
+		if err != nil {
+			return err
+		}
+		ctx.OverrideLaunchEnv(jre, "JAVA_HOME", jre.Root)
 	}
 
-	releaseURL := fmt.Sprintf(javaVersionURL, featureVersion)
+	ctx.AddBuildpackPlan(buildpackplan.Plan{
+		Name:    javaPlan,
+		Version: version,
+	})
+	return nil
+}
+
+// install installs the latest release of the image type, `jdk` or `jre`, for the feature version in the named layer,
+// returning the layer and the installed version.
+func install(ctx *gcp.Context, name, imageType, featureVersion string, flags ...layers.Flag) (*layers.Layer, string, error) {
+	releaseURL := fmt.Sprintf(javaVersionURL, featureVersion, imageType)
 	if code := ctx.HTTPStatus(releaseURL); code != http.StatusOK {
-		return gcp.UserErrorf("Java feature version %s does not exist at %s (status %d). You can specify the feature version with %s. See available feature runtime versions at https://api.adoptopenjdk.net/v3/info/available_releases", featureVersion, releaseURL, code, env.RuntimeVersion)
+		return nil, "", gcp.UserErrorf("Java feature version %s does not exist at %s (status %d). You can specify the feature version with %s. See available feature runtime versions at https://api.adoptopenjdk.net/v3/info/available_releases", featureVersion, releaseURL, code, env.RuntimeVersion)
 	}
 
 	result := ctx.Exec([]string{"curl", "--silent", releaseURL})
 	release, err := parseVersionJSON(result.Stdout)
 	if err != nil {
-		return fmt.Errorf("parsing JSON returned by %s: %w", releaseURL, err)
+		return nil, "", fmt.Errorf("parsing JSON returned by %s: %w", releaseURL, err)
 	}
 
-	version, archiveURL, err := extractRelease(release)
+	version, archiveURL, err := extractRelease(release, imageType)
 	if err != nil {
-		return fmt.Errorf("extracting release returned by %s: %w", releaseURL, err)
+		return nil, "", fmt.Errorf("extracting release returned by %s: %w", releaseURL, err)
 	}
 
 	// Check the metadata in the cache layer to determine if we need to proceed.
 	var meta metadata
-	l := ctx.Layer(javaLayer)
+	l := ctx.Layer(name)
 	ctx.ReadMetadata(l, &meta)
 	if version == meta.Version {
-		ctx.CacheHit(javaLayer)
-		return nil
+		ctx.CacheHit(name)
+		return l, version, nil
 	}
-	ctx.CacheMiss(javaLayer)
-	ctx.ClearLayer(l)
+	ctx.CacheMiss(name)
+	if err := runtime.InstallVersion(ctx, l, version, func(dir string) error {
+		// Download and install Java in the version cache.
+		ctx.Logf("Installing Java %s v%s", strings.ToUpper(imageType), version)
 
-	// Download and install Java in layer.
-	ctx.Logf("Installing Java v%s", version)
-
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, l.Root)
-	ctx.Exec([]string{"bash", "-c", command})
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, dir)
+		ctx.Exec([]string{"bash", "-c", command})
+		return nil
+	}); err != nil {
+		return nil, "", err
+	}
 
 	meta.Version = version
-	ctx.WriteMetadata(l, meta, layers.Build, layers.Cache, layers.Launch)
+	ctx.WriteMetadata(l, meta, flags...)
+	return l, version, nil
+}
 
-	ctx.AddBuildpackPlan(buildpackplan.Plan{
-		Name:    javaLayer,
-		Version: version,
-	})
-	return nil
+// useJlink returns true if the launch runtime is created with jlink by the java/entrypoint buildpack.
+// This requires the JDK at javaHome to include jlink, and that the java/entrypoint buildpack runs: an entrypoint specified with
+// GOOGLE_ENTRYPOINT or a Procfile is set by the config/entrypoint buildpack instead.
+func useJlink(ctx *gcp.Context, featureVersion, javaHome string) bool {
+	if !java.JlinkEnabled(ctx) {
+		return false
+	}
+	if !java.SupportsJlink(featureVersion) {
+		ctx.Warnf("Ignoring %s, jlink is not available in Java %s.", env.JavaJlink, featureVersion)
+		return false
+	}
+	if java.JDKFeatureVersion(ctx, javaHome) == "" {
+		ctx.Warnf("Ignoring %s, jlink was not found in the JDK at %s.", env.JavaJlink, javaHome)
+		return false
+	}
+	if os.Getenv(env.Entrypoint) != "" {
+		ctx.Warnf("Ignoring %s, jlink is not supported with %s.", env.JavaJlink, env.Entrypoint)
+		return false
+	}
+	if ctx.FileExists(ctx.ApplicationRoot(), "Procfile") {
+		ctx.Warnf("Ignoring %s, jlink is not supported with a Procfile.", env.JavaJlink)
+		return false
+	}
+	return true
+}
+
+// runtimeFeatureVersion returns the Java feature version to install, from the env var, the application's build files
+// or the default.
+func runtimeFeatureVersion(ctx *gcp.Context) (string, error) {
+	if v := os.Getenv(env.RuntimeVersion); v != "" {
+		ctx.Logf("Using requested runtime feature version: %s", v)
+		return v, nil
+	}
+	v, file, err := java.FeatureVersion(ctx)
+	if err != nil {
+		return "", err
+	}
+	if v != "" {
+		ctx.Logf("Using runtime feature version %s from %s. You can specify a different version with %s.", v, file, env.RuntimeVersion)
+		return v, nil
+	}
+	ctx.Logf("Using latest Java %s runtime version. You can specify a different version with %s: https://github.com/GoogleCloudPlatform/buildpacks#configuration", defaultFeatureVersion, env.RuntimeVersion)
+	return defaultFeatureVersion, nil
 }
 
 type binaryPkg struct {
@@ -142,17 +222,17 @@ func parseVersionJSON(jsonStr string) (javaRelease, error) {
 	return releases[0], nil
 }
 
-// extractRelease returns the version name and archiveURL from a javaRelease.
-func extractRelease(release javaRelease) (string, string, error) {
+// extractRelease returns the version name and archiveURL of the image type, `jdk` or `jre`, from a javaRelease.
+func extractRelease(release javaRelease, imageType string) (string, string, error) {
 	if len(release.Binaries) == 0 {
 		return "", "", fmt.Errorf("no binaries in given release %s", release.VersionData.Semver)
 	}
 
 	for _, binary := range release.Binaries {
-		if binary.ImageType == "jdk" && binary.OS == "linux" && binary.Architecture == "x64" {
+		if binary.ImageType == imageType && binary.OS == "linux" && binary.Architecture == "x64" {
 			return release.VersionData.Semver, binary.BinaryPkg.Link, nil
 		}
 	}
 
-	return "", "", fmt.Errorf("jdk/linux/x64 binary not found in release %s", release.VersionData.Semver)
+	return "", "", fmt.Errorf("%s/linux/x64 binary not found in release %s", imageType, release.VersionData.Semver)
 }
@@ -15,10 +15,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -272,7 +277,7 @@ func TestExtractRelease(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			gotVersion, gotBinaryLink, err := extractRelease(tc.javaRelease)
+			gotVersion, gotBinaryLink, err := extractRelease(tc.javaRelease, "jdk")
 			if err != nil {
 				t.Fatalf("extractRelease() returned error: %v", err)
 			}
@@ -321,10 +326,104 @@ func TestExtractReleaseFail(t *testing.T) {
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			_, _, err := extractRelease(tc.javaRelease)
+			_, _, err := extractRelease(tc.javaRelease, "jdk")
 			if err == nil {
 				t.Error("extractRelease() did not return error.")
 			}
 		})
 	}
 }
+
+func TestUseJlink(t *testing.T) {
+	testCases := []struct {
+		name           string
+		env            map[string]string
+		files          map[string]string
+		jdkFiles       map[string]string
+		featureVersion string
+		want           bool
+	}{
+		{
+			name:           "not requested",
+			featureVersion: "11",
+			want:           false,
+		},
+		{
+			name:           "requested",
+			env:            map[string]string{env.JavaJlink: "true"},
+			jdkFiles:       map[string]string{"bin/jlink": "", "release": `JAVA_VERSION="11.0.6"`},
+			featureVersion: "11",
+			want:           true,
+		},
+		{
+			name:           "jdk without jlink",
+			env:            map[string]string{env.JavaJlink: "true"},
+			jdkFiles:       map[string]string{"release": `JAVA_VERSION="11.0.6"`},
+			featureVersion: "11",
+			want:           false,
+		},
+		{
+			name:           "java 8",
+			env:            map[string]string{env.JavaJlink: "true"},
+			featureVersion: "8",
+			want:           false,
+		},
+		{
+			name:           "custom entrypoint",
+			env:            map[string]string{env.JavaJlink: "true", env.Entrypoint: "java -jar app.jar"},
+			jdkFiles:       map[string]string{"bin/jlink": "", "release": `JAVA_VERSION="11.0.6"`},
+			featureVersion: "11",
+			want:           false,
+		},
+		{
+			name:           "procfile",
+			env:            map[string]string{env.JavaJlink: "true"},
+			files:          map[string]string{"Procfile": "web: java -jar app.jar"},
+			jdkFiles:       map[string]string{"bin/jlink": "", "release": `JAVA_VERSION="11.0.6"`},
+			featureVersion: "11",
+			want:           false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			for k, v := range tc.env {
+				if err := os.Setenv(k, v); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func(k string) {
+					if err := os.Unsetenv(k); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}(k)
+			}
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for f, c := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			jdk, err := ioutil.TempDir("", "jdk-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(jdk)
+			for f, c := range tc.jdkFiles {
+				if err := os.MkdirAll(filepath.Dir(filepath.Join(jdk, f)), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(jdk, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{ID: "id", Version: "version", Name: "name"}, dir)
+
+			if got := useJlink(ctx, tc.featureVersion, jdk); got != tc.want {
+				t.Errorf("useJlink(%q) = %t, want %t", tc.featureVersion, got, tc.want)
+			}
+		})
+	}
+}
@@ -20,18 +20,32 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/blang/semver"
 	"github.com/buildpack/libbuildpack/layers"
 )
 
 const (
-	layerName = "functions-framework"
+	layerName        = "functions-framework"
+	typescriptLayer  = "typescript"
+	frameworkPackage = "@google-cloud/functions-framework"
 )
 
+var (
+	// minESMFrameworkVersion is the first functions-framework version that loads functions written as ES modules.
+	minESMFrameworkVersion = semver.MustParse("1.9.0")
+)
+
+// typescriptMetadata represents metadata stored for the typescript layer.
+type typescriptMetadata struct {
+	Version string `toml:"version"`
+}
+
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -45,36 +59,51 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	if _, ok := os.LookupEnv(env.FunctionSource); ok {
-		return gcp.UserErrorf("%s is not currently supported for Node.js buildpacks", env.FunctionSource)
+	src, err := functionSource(ctx)
+	if err != nil {
+		return err
 	}
 
 	// Function source code should be defined in the "main" field in package.json, index.js or function.js.
 	// https://cloud.google.com/functions/docs/writing#structuring_source_code
 	fnFile := "function.js"
-	if ctx.FileExists("index.js") {
+	if ctx.FileExists(src, "index.js") {
 		fnFile = "index.js"
 	}
 
 	// Determine if the function has dependency on functions-framework.
 	hasFrameworkDependency := false
-	if ctx.FileExists("package.json") {
-		pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
-		if err != nil {
+	pjs := &nodejs.PackageJSON{}
+	if ctx.FileExists(src, "package.json") {
+		if pjs, err = nodejs.ReadPackageJSON(src); err != nil {
 			return fmt.Errorf("reading package.json: %w", err)
 		}
-		_, hasFrameworkDependency = pjs.Dependencies["@google-cloud/functions-framework"]
+		_, hasFrameworkDependency = pjs.Dependencies[frameworkPackage]
 		if pjs.Main != "" {
 			fnFile = pjs.Main
 		}
 	}
 
-	if !ctx.FileExists(fnFile) {
+	if !ctx.FileExists(src, fnFile) && ctx.FileExists(src, "tsconfig.json") {
+		// main points to the output of a TypeScript project that has not been compiled by a build script.
+		compileTypeScript(ctx, src, pjs)
+	}
+	if !ctx.FileExists(src, fnFile) {
 		return gcp.UserErrorf("%s does not exist", fnFile)
 	}
 
-	// Syntax check the function code without executing.
-	ctx.ExecUser([]string{"node", "--check", fnFile})
+	if isESM(pjs, fnFile) {
+		// ES modules are loaded with import(), which the functions-framework supports since 1.9.0.
+		v := frameworkVersion(ctx, src)
+		if !hasFrameworkDependency || v == "" || semver.MustParse(v).LT(minESMFrameworkVersion) {
+			return gcp.UserErrorf("functions written as ES modules require a dependency on %s %s or later in package.json", frameworkPackage, minESMFrameworkVersion)
+		}
+		// node --check parses files as CommonJS, so it cannot check ES modules.
+		ctx.Debugf("Skipping syntax check of ES module %s.", fnFile)
+	} else {
+		// Syntax check the function code without executing.
+		ctx.ExecUser([]string{"node", "--check", filepath.Join(src, fnFile)})
+	}
 
 	cvt := filepath.Join(ctx.BuildpackRoot(), "converter")
 	if hasFrameworkDependency {
@@ -88,10 +117,10 @@ func buildFn(ctx *gcp.Context) error {
 	// Install functions-framework.
 	l := ctx.Layer(layerName)
 	nm := filepath.Join(l.Root, "node_modules")
-	pjs := filepath.Join(cvt, "package.json")
-	pljs := filepath.Join(cvt, nodejs.PackageLock)
+	cvtpjs := filepath.Join(cvt, "package.json")
+	cvtpljs := filepath.Join(cvt, nodejs.PackageLock)
 
-	cached, meta, err := nodejs.CheckCache(ctx, l, cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(pjs, pljs))
+	cached, meta, err := nodejs.CheckCache(ctx, l, cache.WithStrings(nodejs.EnvProduction), cache.WithFiles(cvtpjs, cvtpljs))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
 	}
@@ -101,7 +130,7 @@ func buildFn(ctx *gcp.Context) error {
 		ctx.CacheMiss(layerName)
 		ctx.ClearLayer(l)
 		// NPM expects package.json and the lock file in the prefix directory.
-		ctx.Exec([]string{"cp", "-t", l.Root, pjs, pljs})
+		ctx.Exec([]string{"cp", "-t", l.Root, cvtpjs, cvtpljs})
 		ctx.ExecUser([]string{"npm", nodejs.NPMInstallCommand(ctx), "--quiet", "--production", "--prefix", l.Root})
 	}
 
@@ -111,7 +140,15 @@ func buildFn(ctx *gcp.Context) error {
 	// Else, it is installed in functions-framework layer's node_modules.
 	ff := filepath.Join(".bin", "functions-framework")
 	if hasFrameworkDependency {
+		// Workspace dependencies may be installed in the workspace or hoisted to the application root.
 		ff = filepath.Join("node_modules", ff)
+		if src != ctx.ApplicationRoot() {
+			if ctx.FileExists(src, ff) {
+				ff = filepath.Join(src, ff)
+			} else {
+				ff = filepath.Join(ctx.ApplicationRoot(), ff)
+			}
+		}
 	} else {
 		ff = filepath.Join(nm, ff)
 
@@ -124,8 +161,106 @@ func buildFn(ctx *gcp.Context) error {
 
 	ctx.SetFunctionsEnvVars(l)
 
+	if src != ctx.ApplicationRoot() {
+		// functions-framework loads the function from the working directory or, since 1.7.0, FUNCTION_SOURCE which
+		// must then be absolute.
+		ctx.OverrideLaunchEnv(l, env.FunctionSourceLaunch, src)
+		ff = fmt.Sprintf("cd %s && exec %s", src, ff)
+	}
 	ctx.AddWebProcess([]string{"/bin/bash", "-c", ff})
 	ctx.WriteMetadata(l, &meta, layers.Build, layers.Cache, layers.Launch)
 
 	return nil
 }
+
+// functionSource returns the absolute path of the directory containing the function: GOOGLE_FUNCTION_SOURCE if set,
+// the workspace selected by GOOGLE_BUILDABLE, or the application root.
+func functionSource(ctx *gcp.Context) (string, error) {
+	root := ctx.ApplicationRoot()
+	if fs, ok := os.LookupEnv(env.FunctionSource); ok {
+		src := filepath.Join(root, fs)
+		if rel, err := filepath.Rel(root, src); err != nil || strings.HasPrefix(rel, "..") {
+			return "", gcp.UserErrorf("%s=%q must be a directory within the application", env.FunctionSource, fs)
+		}
+		if fi, err := os.Stat(src); err != nil || !fi.IsDir() {
+			return "", gcp.UserErrorf("%s=%q is not a directory", env.FunctionSource, fs)
+		}
+		ctx.Logf("Using function source in %s.", fs)
+		return src, nil
+	}
+	if !ctx.FileExists(root, "package.json") {
+		return root, nil
+	}
+	// In a workspace monorepo, the function is the workspace selected by GOOGLE_BUILDABLE.
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return "", err
+	}
+	if len(wss) > 0 {
+		ws := wss[len(wss)-1]
+		ctx.Logf("Using function source in workspace %s.", ws.Dir)
+		return filepath.Join(root, ws.Dir), nil
+	}
+	return root, nil
+}
+
+// isESM returns true if the function file is an ES module.
+func isESM(pjs *nodejs.PackageJSON, fnFile string) bool {
+	switch filepath.Ext(fnFile) {
+	case ".mjs":
+		return true
+	case ".cjs":
+		return false
+	}
+	return pjs.Type == "module"
+}
+
+// frameworkVersion returns the version of the functions-framework installed for the function in src, or an empty
+// string if it is not installed.
+func frameworkVersion(ctx *gcp.Context, src string) string {
+	for _, dir := range []string{src, ctx.ApplicationRoot()} {
+		pjs, err := nodejs.ReadPackageJSON(filepath.Join(dir, "node_modules", frameworkPackage))
+		if err != nil {
+			continue
+		}
+		if _, err := semver.Parse(pjs.Version); err == nil {
+			return pjs.Version
+		}
+	}
+	return ""
+}
+
+// compileTypeScript compiles the TypeScript project in src with the tsc of its dependencies or, as production
+// builds do not install devDependencies, with the TypeScript version it requires installed in a layer.
+func compileTypeScript(ctx *gcp.Context, src string, pjs *nodejs.PackageJSON) {
+	tsc := filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin", "tsc")
+	if !ctx.FileExists(tsc) {
+		v := pjs.DevDependencies["typescript"]
+		if v == "" {
+			v = pjs.Dependencies["typescript"]
+		}
+		if v == "" {
+			v = "latest"
+		}
+		tl := ctx.Layer(typescriptLayer)
+		var meta typescriptMetadata
+		ctx.ReadMetadata(tl, &meta)
+		if meta.Version == v {
+			ctx.CacheHit(typescriptLayer)
+		} else {
+			ctx.CacheMiss(typescriptLayer)
+			ctx.ClearLayer(tl)
+			ctx.Logf("Installing TypeScript %s", v)
+			ctx.ExecUser([]string{"npm", "install", "--quiet", "--no-save", "--prefix", tl.Root, "typescript@" + v})
+		}
+		meta.Version = v
+		ctx.WriteMetadata(tl, meta, layers.Build, layers.Cache)
+		tsc = filepath.Join(tl.Root, "node_modules", ".bin", "tsc")
+	}
+
+	ctx.Logf("Compiling TypeScript project in %s", src)
+	ctx.ExecUserWithParams(gcp.ExecParams{
+		Cmd: []string{tsc, "--project", src},
+		Dir: src,
+	}, gcp.UserErrorKeepStdoutHead)
+}
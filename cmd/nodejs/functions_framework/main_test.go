@@ -15,9 +15,14 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -44,3 +49,143 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestFunctionSource(t *testing.T) {
+	files := map[string]string{
+		"package.json":                 `{"workspaces": ["functions/*"]}`,
+		"functions/hello/package.json": `{"name": "hello"}`,
+		"functions/hello/index.js":     "",
+		"README.md":                    "",
+	}
+	testCases := []struct {
+		name    string
+		env     map[string]string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "application root",
+			want: ".",
+		},
+		{
+			name: "function source",
+			env:  map[string]string{"GOOGLE_FUNCTION_SOURCE": "functions/hello"},
+			want: "functions/hello",
+		},
+		{
+			name: "workspace",
+			env:  map[string]string{"GOOGLE_BUILDABLE": "hello"},
+			want: "functions/hello",
+		},
+		{
+			name:    "function source outside of application",
+			env:     map[string]string{"GOOGLE_FUNCTION_SOURCE": "../hello"},
+			wantErr: true,
+		},
+		{
+			name:    "function source not a directory",
+			env:     map[string]string{"GOOGLE_FUNCTION_SOURCE": "README.md"},
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, files)
+			defer os.RemoveAll(dir)
+			for k, v := range tc.env {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := functionSource(ctx)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("functionSource() = %q, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("functionSource() got error: %v", err)
+			}
+			if want := filepath.Join(dir, tc.want); got != want {
+				t.Errorf("functionSource() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestIsESM(t *testing.T) {
+	testCases := []struct {
+		pkgType string
+		fnFile  string
+		want    bool
+	}{
+		{fnFile: "index.js"},
+		{fnFile: "index.mjs", want: true},
+		{pkgType: "module", fnFile: "index.js", want: true},
+		{pkgType: "module", fnFile: "index.cjs"},
+		{pkgType: "commonjs", fnFile: "dist/index.js"},
+	}
+	for _, tc := range testCases {
+		if got := isESM(&nodejs.PackageJSON{Type: tc.pkgType}, tc.fnFile); got != tc.want {
+			t.Errorf("isESM(type %q, %q) = %t, want %t", tc.pkgType, tc.fnFile, got, tc.want)
+		}
+	}
+}
+
+func TestFrameworkVersion(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "not installed",
+		},
+		{
+			name: "installed in application root",
+			files: map[string]string{
+				"node_modules/@google-cloud/functions-framework/package.json": `{"version": "1.9.0"}`,
+			},
+			want: "1.9.0",
+		},
+		{
+			name: "installed in function source",
+			files: map[string]string{
+				"node_modules/@google-cloud/functions-framework/package.json":    `{"version": "1.5.1"}`,
+				"fn/node_modules/@google-cloud/functions-framework/package.json": `{"version": "1.9.0"}`,
+			},
+			want: "1.9.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			if got := frameworkVersion(ctx, filepath.Join(dir, "fn")); got != tc.want {
+				t.Errorf("frameworkVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeApp creates a temporary application directory containing files.
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "app-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	for f, c := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", f, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	return dir
+}
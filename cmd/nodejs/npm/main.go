@@ -46,9 +46,15 @@ func buildFn(ctx *gcp.Context) error {
 	ml := ctx.Layer("npm")
 	nm := filepath.Join(ml.Root, "node_modules")
 	ctx.RemoveAll("node_modules")
+	removeAuth, err := nodejs.RegistryAuth(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeAuth()
 	nodejs.EnsurePackageLock(ctx)
 
 	nodeEnv := nodejs.NodeEnv()
+	nodejs.CacheNodeGypHeaders(ctx)
 	cached, meta, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", nodejs.PackageLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
@@ -77,10 +83,20 @@ func buildFn(ctx *gcp.Context) error {
 		// Ensure node_modules exists even if no dependencies were installed.
 		ctx.MkdirAll("node_modules", 0755)
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm})
+		if err := nodejs.RecordNativeAddons(ctx, meta); err != nil {
+			return err
+		}
 	}
+	// Registry credentials are only available while installing dependencies.
+	removeAuth()
 
 	ctx.WriteMetadata(ml, &meta, layers.Build, layers.Cache)
 
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+
 	el := ctx.Layer("env")
 	ctx.PrependPathSharedEnv(el, "PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	ctx.DefaultSharedEnv(el, "NODE_ENV", nodeEnv)
@@ -88,6 +104,18 @@ func buildFn(ctx *gcp.Context) error {
 
 	// Configure the entrypoint for production.
 	cmd := []string{"npm", "start"}
+	if len(wss) > 0 {
+		// Start the workspace selected by GOOGLE_BUILDABLE.
+		cmd = append(cmd, "--prefix", wss[len(wss)-1].Dir)
+	} else {
+		fc, err := nodejs.FrameworkStartCommand(ctx, []string{"npx", "--no-install"})
+		if err != nil {
+			return err
+		}
+		if fc != nil {
+			cmd = fc
+		}
+	}
 
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
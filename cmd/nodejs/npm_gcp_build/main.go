@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // Implements nodejs/npm_gcp_build buildpack.
-// The npm_gcp_build buildpack runs the 'gcp-build' script in package.json using npm.
+// The npm_gcp_build buildpack runs the build scripts in package.json, gcp-build or build by default, using npm.
 package main
 
 import (
@@ -21,6 +21,7 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/buildpack/libbuildpack/layers"
@@ -39,12 +40,23 @@ func detectFn(ctx *gcp.Context) error {
 		ctx.OptOut("package.json not found.")
 	}
 
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	if len(wss) > 0 {
+		if !nodejs.HasBuildScripts(wss) {
+			ctx.OptOut("No build scripts found in workspace %s or its dependencies.", wss[len(wss)-1].Dir)
+		}
+		return nil
+	}
+
 	p, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
 	if err != nil {
 		return fmt.Errorf("reading package.json: %w", err)
 	}
-	if p.Scripts.GCPBuild == "" {
-		ctx.OptOut("gcp-build script not found in package.json.")
+	if len(p.BuildScripts()) == 0 && nodejs.DetectFramework(p) == nil {
+		ctx.OptOut("No build scripts found in package.json, set %s to run scripts other than gcp-build or build.", env.NodeRunScripts)
 	}
 
 	return nil
@@ -54,9 +66,15 @@ func buildFn(ctx *gcp.Context) error {
 	l := ctx.Layer("npm")
 	nm := filepath.Join(l.Root, "node_modules")
 	ctx.RemoveAll("node_modules")
+	removeAuth, err := nodejs.RegistryAuth(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeAuth()
 	nodejs.EnsurePackageLock(ctx)
 
 	nodeEnv := nodejs.EnvDevelopment
+	nodejs.CacheNodeGypHeaders(ctx)
 	cached, meta, err := nodejs.CheckCache(ctx, l, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", nodejs.PackageLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
@@ -76,9 +94,34 @@ func buildFn(ctx *gcp.Context) error {
 		// Ensure node_modules exists even if no dependencies were installed.
 		ctx.MkdirAll("node_modules", 0755)
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm})
+		if err := nodejs.RecordNativeAddons(ctx, meta); err != nil {
+			return err
+		}
 	}
+	// Registry credentials are only available while installing dependencies, not to build scripts.
+	removeAuth()
 
-	ctx.ExecUser([]string{"npm", "run", "gcp-build"})
+	// In a workspace monorepo, only the workspace selected by GOOGLE_BUILDABLE and its dependencies are built.
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	if len(wss) > 0 {
+		nodejs.BuildWorkspaces(ctx, wss, []string{"npm", "run"})
+	} else {
+		pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+		if err != nil {
+			return fmt.Errorf("reading package.json: %w", err)
+		}
+		if fw := nodejs.DetectFramework(pjs); fw != nil {
+			nodejs.BuildFramework(ctx, fw, pjs, []string{"npm", "run"}, []string{"npx", "--no-install"})
+		} else {
+			for _, script := range pjs.BuildScripts() {
+				ctx.ExecUser([]string{"npm", "run", script})
+			}
+		}
+	}
+	// Remove devDependencies, the next buildpack installs production dependencies for launch.
 	ctx.RemoveAll("node_modules")
 	ctx.WriteMetadata(l, &meta, layers.Cache)
 	return nil
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements nodejs/pnpm buildpack.
+// The pnpm buildpack installs dependencies using pnpm and installs pnpm itself.
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
+	"github.com/buildpack/libbuildpack/buildpackplan"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	pnpmLayer  = "pnpm"
+	storeLayer = "pnpm_store"
+	// defaultPNPMVersion is installed unless the packageManager field of package.json specifies a version.
+	defaultPNPMVersion = "5.18.9"
+)
+
+// metadata represents metadata stored for a pnpm layer.
+type metadata struct {
+	Version string `toml:"version"`
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(nodejs.PNPMLock) {
+		ctx.OptOut("%s not found.", nodejs.PNPMLock)
+	}
+	if !ctx.FileExists("package.json") {
+		ctx.OptOut("package.json not found.")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return fmt.Errorf("reading package.json: %w", err)
+	}
+	version := pjs.PackageManagerVersion("pnpm")
+	if version == "" {
+		version = defaultPNPMVersion
+	}
+	installPNPM(ctx, version)
+
+	// The content-addressable store holds every package version pnpm has downloaded, so only new packages are fetched.
+	sl := ctx.Layer(storeLayer)
+	ctx.WriteMetadata(sl, nil, layers.Cache)
+
+	nodejs.CacheNodeGypHeaders(ctx)
+	ctx.RemoveAll("node_modules")
+	nodeEnv := nodejs.NodeEnv()
+	scripts := pjs.BuildScripts()
+	fw := nodejs.DetectFramework(pjs)
+	installEnv := nodeEnv
+	if len(scripts) > 0 || fw != nil {
+		// Build scripts may need devDependencies, which are pruned after they run.
+		installEnv = nodejs.EnvDevelopment
+	}
+	removeAuth, err := nodejs.RegistryAuth(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeAuth()
+	ctx.ExecUserWithParams(gcp.ExecParams{
+		Cmd: []string{"pnpm", "install", "--frozen-lockfile", "--store-dir", sl.Root},
+		Env: []string{"NODE_ENV=" + installEnv},
+	}, gcp.UserErrorKeepStderrTail)
+	// Registry credentials are only available while installing dependencies, not to build scripts.
+	removeAuth()
+
+	if len(scripts) > 0 || fw != nil {
+		if fw != nil {
+			nodejs.BuildFramework(ctx, fw, pjs, []string{"pnpm", "run"}, []string{"pnpm", "exec"})
+		} else {
+			for _, script := range scripts {
+				ctx.ExecUserWithParams(gcp.ExecParams{
+					Cmd: []string{"pnpm", "run", script},
+					Env: []string{"NODE_ENV=" + nodejs.EnvDevelopment},
+				}, gcp.UserErrorKeepStderrTail)
+			}
+		}
+		if nodeEnv == nodejs.EnvProduction {
+			ctx.ExecUserWithParams(gcp.ExecParams{
+				Cmd: []string{"pnpm", "prune", "--prod", "--store-dir", sl.Root},
+				Env: []string{"NODE_ENV=" + nodeEnv},
+			}, gcp.UserErrorKeepStderrTail)
+		}
+	}
+
+	el := ctx.Layer("env")
+	ctx.PrependPathSharedEnv(el, "PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
+	ctx.DefaultSharedEnv(el, "NODE_ENV", nodeEnv)
+	ctx.WriteMetadata(el, nil, layers.Launch, layers.Build)
+
+	// Configure the entrypoint for production.
+	cmd := []string{"pnpm", "run", "start"}
+	fc, err := nodejs.FrameworkStartCommand(ctx, []string{"pnpm", "exec"})
+	if err != nil {
+		return err
+	}
+	if fc != nil {
+		cmd = fc
+	}
+
+	if !devmode.Enabled(ctx) {
+		ctx.AddWebProcess(cmd)
+		return nil
+	}
+
+	// Configure the entrypoint and metadata for dev mode.
+	devmode.AddFileWatcherProcess(ctx, devmode.Config{
+		Cmd: cmd,
+		Ext: devmode.NodeWatchedExtensions,
+	})
+	devmode.AddSyncMetadata(ctx, devmode.NodeSyncRules)
+
+	return nil
+}
+
+// installPNPM installs the given version of pnpm in a layer and adds it to PATH.
+func installPNPM(ctx *gcp.Context, version string) {
+	pl := ctx.Layer(pnpmLayer)
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	var meta metadata
+	ctx.ReadMetadata(pl, &meta)
+	if version == meta.Version {
+		ctx.CacheHit(pnpmLayer)
+		ctx.Logf("pnpm cache hit, skipping installation.")
+	} else {
+		ctx.CacheMiss(pnpmLayer)
+		ctx.ClearLayer(pl)
+
+		ctx.Logf("Installing pnpm v%s", version)
+		ctx.ExecUser([]string{"npm", "install", "--global", "--prefix", pl.Root, "--quiet", "pnpm@" + version})
+	}
+
+	// Store layer flags and metadata.
+	meta.Version = version
+	ctx.WriteMetadata(pl, meta, layers.Build, layers.Cache, layers.Launch)
+	ctx.Setenv("PATH", filepath.Join(pl.Root, "bin")+":"+os.Getenv("PATH"))
+
+	ctx.AddBuildpackPlan(buildpackplan.Plan{
+		Name:    pnpmLayer,
+		Version: version,
+	})
+}
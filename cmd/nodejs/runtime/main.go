@@ -19,7 +19,8 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -73,18 +74,21 @@ func buildFn(ctx *gcp.Context) error {
 		return nil
 	}
 	ctx.CacheMiss(nodeLayer)
-	ctx.ClearLayer(nrl)
+	if err := runtime.InstallVersion(ctx, nrl, version, func(dir string) error {
+		archiveURL := fmt.Sprintf(nodeURL, version)
+		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		}
 
-	archiveURL := fmt.Sprintf(nodeURL, version)
-	if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
-		return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		// Download and install Node.js in the version cache.
+		ctx.Logf("Installing Node.js v%s", version)
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xJ --directory %s --strip-components=1", archiveURL, dir)
+		ctx.Exec([]string{"bash", "-c", command})
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	// Download and install Node.js in layer.
-	ctx.Logf("Installing Node.js v%s", version)
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xJ --directory %s --strip-components=1", archiveURL, nrl.Root)
-	ctx.Exec([]string{"bash", "-c", command})
-
 	meta.Version = version
 	ctx.WriteMetadata(nrl, meta, layers.Build, layers.Cache, layers.Launch)
 
@@ -95,26 +99,58 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// versionFiles pin the Node.js version, in order of precedence. Both hold a single version or alias, e.g. `lts/*`.
+var versionFiles = []string{".nvmrc", ".node-version"}
+
 // runtimeVersion returns the version of the runtime to install.
-// The version is read from env var if set or determined based on the `engines` field in package.json.
+// The version is read from the env var, .nvmrc, .node-version or the `engines` field in package.json, in that order.
+// When several sources are present, the first one wins and a warning is logged for each one that disagrees with it.
 func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if version := os.Getenv(env.RuntimeVersion); version != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, version)
-		return version, nil
+	constraints, err := versionConstraints(ctx)
+	if err != nil {
+		return "", err
 	}
-	// The default empty range returns the latest version.
-	var versionRange string
-	if ctx.FileExists("package.json") {
+	return runtime.ResolveFirst(ctx, runtime.Nodejs, constraints)
+}
+
+// versionConstraints returns all Node.js version constraints specified for the application, in order of precedence.
+func versionConstraints(ctx *gcp.Context) ([]runtime.Constraint, error) {
+	var constraints []runtime.Constraint
+	if c, ok := runtime.EnvConstraint(); ok {
+		constraints = append(constraints, c)
+	}
+	for _, f := range versionFiles {
+		path := filepath.Join(ctx.ApplicationRoot(), f)
+		if !ctx.FileExists(path) {
+			continue
+		}
+		v := parseVersionFile(ctx.ReadFile(path))
+		if v == "" {
+			return nil, gcp.UserErrorf("%s exists but does not specify a version", f)
+		}
+		constraints = append(constraints, runtime.Constraint{Value: v, Source: f})
+	}
+	if ctx.FileExists(filepath.Join(ctx.ApplicationRoot(), "package.json")) {
 		pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
 		if err != nil {
-			return "", fmt.Errorf("reading package.json: %w", err)
+			return nil, fmt.Errorf("reading package.json: %w", err)
+		}
+		if v := strings.TrimSpace(pjs.Engines.Node); v != "" {
+			constraints = append(constraints, runtime.Constraint{Value: v, Source: "package.json"})
+		}
+	}
+	return constraints, nil
+}
+
+// parseVersionFile returns the first line of a version file that is not blank or a comment.
+func parseVersionFile(b []byte) string {
+	for _, line := range strings.Split(string(b), "\n") {
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		if line = strings.TrimSpace(line); line != "" {
+			return line
 		}
-		versionRange = pjs.Engines.Node
 	}
-	// Use package.json and semver.io to determine best-fit Node.js version.
-	ctx.Logf("Resolving Node.js version based on semver %q", versionRange)
-	result := ctx.Exec([]string{"curl", "--silent", "--get", "--data-urlencode", fmt.Sprintf("range=%s", versionRange), "http://semver.io/node/resolve"})
-	version := result.Stdout
-	ctx.Logf("Using resolved runtime version from package.json: %s", version)
-	return version, nil
+	return ""
 }
@@ -15,9 +15,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -55,3 +61,122 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionConstraints(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []runtime.Constraint
+	}{
+		{
+			name: "none",
+			files: map[string]string{
+				"package.json": `{"name": "app"}`,
+			},
+		},
+		{
+			name: "package.json",
+			files: map[string]string{
+				"package.json": `{"engines": {"node": "12.x"}}`,
+			},
+			want: []runtime.Constraint{{Value: "12.x", Source: "package.json"}},
+		},
+		{
+			name: "all sources in order",
+			files: map[string]string{
+				".node-version": "14.15.0\n",
+				".nvmrc":        "# Pinned for CI\nlts/fermium\n",
+				"package.json":  `{"engines": {"node": ">=12"}}`,
+			},
+			want: []runtime.Constraint{
+				{Value: "lts/fermium", Source: ".nvmrc"},
+				{Value: "14.15.0", Source: ".node-version"},
+				{Value: ">=12", Source: "package.json"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := versionConstraints(ctx)
+			if err != nil {
+				t.Fatalf("versionConstraints() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("versionConstraints() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRuntimeVersion(t *testing.T) {
+	defer runtime.RegisterIndex(runtime.Nodejs, runtime.StaticIndex{
+		{Version: "15.2.0"},
+		{Version: "14.15.0", LTS: "fermium"},
+		{Version: "14.14.0"},
+		{Version: "12.19.0", LTS: "erbium"},
+	})()
+
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "latest",
+			files: map[string]string{"index.js": ""},
+			want:  "15.2.0",
+		},
+		{
+			name:  "lts alias",
+			files: map[string]string{".nvmrc": "lts/*"},
+			want:  "14.15.0",
+		},
+		{
+			name:  "lts codename",
+			files: map[string]string{".node-version": "lts/erbium"},
+			want:  "12.19.0",
+		},
+		{
+			name: "nvmrc takes precedence",
+			files: map[string]string{
+				".nvmrc":       "14",
+				"package.json": `{"engines": {"node": "12.x"}}`,
+			},
+			want: "14.15.0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := runtimeVersion(ctx)
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeApp creates a temporary application directory containing files.
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "app-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	for f, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	return dir
+}
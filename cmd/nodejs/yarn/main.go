@@ -13,32 +13,26 @@
 // limitations under the License.
 
 // Implements nodejs/yarn buildpack.
-// The npm buildpack installs dependencies using yarn and installs yarn itself if not present.
+// The yarn buildpack installs dependencies using yarn and installs yarn itself if not present.
+// Yarn 2+ (Berry) applications are built with the Yarn release checked into the application.
 package main
 
 import (
 	"fmt"
-	"os"
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/devmode"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
-	"github.com/buildpack/libbuildpack/buildpackplan"
 	"github.com/buildpack/libbuildpack/layers"
 )
 
 const (
-	cacheTag = "prod dependencies"
-	yarnURL  = "https://github.com/yarnpkg/yarn/releases/download/v%[1]s/yarn-v%[1]s.tar.gz"
+	cacheTag        = "prod dependencies"
+	berryCacheLayer = "yarn_cache"
 )
 
-// metadata represents metadata stored for a yarn layer.
-type metadata struct {
-	Version string `toml:"version"`
-}
-
 func main() {
 	gcp.Main(detectFn, buildFn)
 }
@@ -54,56 +48,59 @@ func detectFn(ctx *gcp.Context) error {
 }
 
 func buildFn(ctx *gcp.Context) error {
-	if err := installYarn(ctx); err != nil {
-		return fmt.Errorf("installing Yarn: %w", err)
-	}
-
-	ml := ctx.Layer("yarn")
-	nm := filepath.Join(ml.Root, "node_modules")
+	nodeEnv := nodejs.NodeEnv()
 	ctx.RemoveAll("node_modules")
 
-	nodeEnv := nodejs.NodeEnv()
-	cached, meta, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", nodejs.YarnLock))
+	wss, err := nodejs.BuildableWorkspaces(ctx)
 	if err != nil {
-		return fmt.Errorf("checking cache: %w", err)
+		return err
 	}
 
-	if cached {
-		ctx.CacheHit(cacheTag)
-		// Restore cached node_modules.
-		ctx.Exec([]string{"cp", "--archive", nm, "node_modules"})
+	removeAuth, err := nodejs.RegistryAuth(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeAuth()
+	var yarn []string
+	if nodejs.IsYarnBerry(ctx) {
+		yarn, err = nodejs.InstallYarnBerryDependencies(ctx, berryCacheLayer, nodeEnv)
 	} else {
-		ctx.CacheMiss(cacheTag)
-		// Clear cached node_modules to ensure we don't end up with outdated dependencies.
-		ctx.ClearLayer(ml)
+		yarn, err = installClassicDependencies(ctx, nodeEnv)
 	}
-
-	// Always run yarn install to run preinstall/postinstall scripts.
-	cmd := []string{"yarn", "install", "--non-interactive"}
-	if lf := nodejs.LockfileFlag(ctx); lf != "" {
-		cmd = append(cmd, lf)
+	if err != nil {
+		return err
 	}
-	ctx.ExecUserWithParams(gcp.ExecParams{
-		Cmd: cmd,
-		Env: []string{"NODE_ENV=" + nodeEnv},
-	}, gcp.UserErrorKeepStderrTail)
+	// Registry credentials are only available while installing dependencies.
+	removeAuth()
 
-	if !cached {
-		// Ensure node_modules exists even if no dependencies were installed.
-		ctx.MkdirAll("node_modules", 0755)
-		ctx.Exec([]string{"cp", "--archive", "node_modules", nm})
+	// Configure the entrypoint for production.
+	cmd := append(yarn, "run", "start")
+	if len(wss) > 0 {
+		// Start the workspace selected by GOOGLE_BUILDABLE.
+		ws := wss[len(wss)-1]
+		if ws.Package.Name == "" {
+			return gcp.UserErrorf("workspace %s must have a name in package.json to be started", ws.Dir)
+		}
+		cmd = append(yarn, "workspace", ws.Package.Name, "run", "start")
+	} else {
+		fc, err := nodejs.FrameworkStartCommand(ctx, append(yarn, "run"))
+		if err != nil {
+			return err
+		}
+		if fc != nil {
+			cmd = fc
+		}
 	}
 
-	ctx.WriteMetadata(ml, &meta, layers.Build, layers.Cache)
-
 	el := ctx.Layer("env")
 	ctx.PrependPathSharedEnv(el, "PATH", filepath.Join(ctx.ApplicationRoot(), "node_modules", ".bin"))
 	ctx.DefaultSharedEnv(el, "NODE_ENV", nodeEnv)
+	if pnp := nodejs.PnPLoader(ctx); pnp != "" {
+		// Plug'n'Play resolves dependencies without node_modules, so node must preload the loader.
+		ctx.PrependSharedEnv(el, "NODE_OPTIONS", "--require %s ", pnp)
+	}
 	ctx.WriteMetadata(el, nil, layers.Launch, layers.Build)
 
-	// Configure the entrypoint for production.
-	cmd = []string{"yarn", "run", "start"}
-
 	if !devmode.Enabled(ctx) {
 		ctx.AddWebProcess(cmd)
 		return nil
@@ -119,47 +116,51 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
-func installYarn(ctx *gcp.Context) error {
-	// Skip installation if yarn is already installed.
-	if result := ctx.Exec([]string{"bash", "-c", "command -v yarn || true"}); result.Stdout != "" {
-		ctx.Debugf("Yarn is already installed, skipping installation.")
-		return nil
+// installClassicDependencies installs dependencies with Yarn 1 and returns the command to run yarn.
+func installClassicDependencies(ctx *gcp.Context, nodeEnv string) ([]string, error) {
+	if err := nodejs.InstallYarn(ctx, layers.Build, layers.Cache, layers.Launch); err != nil {
+		return nil, fmt.Errorf("installing Yarn: %w", err)
+	}
+
+	ml := ctx.Layer("yarn")
+	nm := filepath.Join(ml.Root, "node_modules")
+
+	nodejs.CacheNodeGypHeaders(ctx)
+	cached, meta, err := nodejs.CheckCache(ctx, ml, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", nodejs.YarnLock))
+	if err != nil {
+		return nil, fmt.Errorf("checking cache: %w", err)
 	}
 
-	// Use semver.io to determine the latest available version of Yarn.
-	ctx.Logf("Finding latest stable version of Yarn.")
-	result := ctx.Exec([]string{"curl", "--silent", "--get", "http://semver.io/yarn/stable"})
-	version := result.Stdout
-	ctx.Logf("The latest stable version of Yarn is v%s", version)
-
-	yarnLayer := "yarn_install"
-	yrl := ctx.Layer(yarnLayer)
-
-	// Check the metadata in the cache layer to determine if we need to proceed.
-	var meta metadata
-	ctx.ReadMetadata(yrl, &meta)
-	if version == meta.Version {
-		ctx.CacheHit(yarnLayer)
-		ctx.Logf("Yarn cache hit, skipping installation.")
+	if cached {
+		ctx.CacheHit(cacheTag)
+		// Restore cached node_modules.
+		ctx.Exec([]string{"cp", "--archive", nm, "node_modules"})
 	} else {
-		ctx.CacheMiss(yarnLayer)
-		ctx.ClearLayer(yrl)
-
-		// Download and install yarn in layer.
-		ctx.Logf("Installing Yarn v%s", version)
-		archiveURL := fmt.Sprintf(yarnURL, version)
-		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, yrl.Root)
-		ctx.Exec([]string{"bash", "-c", command})
+		ctx.CacheMiss(cacheTag)
+		// Clear cached node_modules to ensure we don't end up with outdated dependencies.
+		ctx.ClearLayer(ml)
+	}
+
+	// Always run yarn install to run preinstall/postinstall scripts.
+	cmd := []string{"yarn", "install", "--non-interactive"}
+	if lf := nodejs.LockfileFlag(ctx); lf != "" {
+		cmd = append(cmd, lf)
+	}
+	ctx.ExecUserWithParams(gcp.ExecParams{
+		Cmd: cmd,
+		Env: []string{"NODE_ENV=" + nodeEnv},
+	}, gcp.UserErrorKeepStderrTail)
+
+	if !cached {
+		// Ensure node_modules exists even if no dependencies were installed.
+		ctx.MkdirAll("node_modules", 0755)
+		ctx.Exec([]string{"cp", "--archive", "node_modules", nm})
+		if err := nodejs.RecordNativeAddons(ctx, meta); err != nil {
+			return nil, err
+		}
 	}
 
-	// Store layer flags and metadata.
-	meta.Version = version
-	ctx.WriteMetadata(yrl, meta, layers.Build, layers.Cache, layers.Launch)
-	ctx.Setenv("PATH", filepath.Join(yrl.Root, "bin")+":"+os.Getenv("PATH"))
+	ctx.WriteMetadata(ml, &meta, layers.Build, layers.Cache)
 
-	ctx.AddBuildpackPlan(buildpackplan.Plan{
-		Name:    yarnLayer,
-		Version: version,
-	})
-	return nil
+	return []string{"yarn"}, nil
 }
@@ -13,7 +13,7 @@
 // limitations under the License.
 
 // Implements nodejs/yarn_gcp_build buildpack.
-// The npm_gcp_build buildpack runs the 'gcp-build' script in package.json using yarn.
+// The yarn_gcp_build buildpack runs the build scripts in package.json, gcp-build or build by default, using yarn.
 package main
 
 import (
@@ -21,13 +21,15 @@ import (
 	"path/filepath"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/nodejs"
 	"github.com/buildpack/libbuildpack/layers"
 )
 
 const (
-	cacheTag string = "dev dependencies"
+	cacheTag        string = "dev dependencies"
+	berryCacheLayer        = "yarn_cache"
 )
 
 func main() {
@@ -42,23 +44,96 @@ func detectFn(ctx *gcp.Context) error {
 		ctx.OptOut("package.json not found.")
 	}
 
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	if len(wss) > 0 {
+		if !nodejs.HasBuildScripts(wss) {
+			ctx.OptOut("No build scripts found in workspace %s or its dependencies.", wss[len(wss)-1].Dir)
+		}
+		return nil
+	}
+
 	p, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
 	if err != nil {
 		return fmt.Errorf("reading package.json: %w", err)
 	}
-	if p.Scripts.GCPBuild == "" {
-		ctx.OptOut("gcp-build script not found in package.json.")
+	if len(p.BuildScripts()) == 0 && nodejs.DetectFramework(p) == nil {
+		ctx.OptOut("No build scripts found in package.json, set %s to run scripts other than gcp-build or build.", env.NodeRunScripts)
 	}
 
 	return nil
 }
 
 func buildFn(ctx *gcp.Context) error {
-	l := ctx.Layer("yarn")
-	nm := filepath.Join(l.Root, "node_modules")
 	ctx.RemoveAll("node_modules")
+	removeAuth, err := nodejs.RegistryAuth(ctx)
+	if err != nil {
+		return err
+	}
+	defer removeAuth()
 
 	nodeEnv := nodejs.EnvDevelopment
+	yarn := []string{"yarn"}
+	// The Yarn cache downloaded by Yarn 2+ (Berry) is removed after the build so that the next buildpack does not
+	// mistake it for a checked-in cache.
+	removeCache := ""
+	if nodejs.IsYarnBerry(ctx) {
+		rc, err := nodejs.ReadYarnRC(ctx)
+		if err != nil {
+			return err
+		}
+		if !rc.CacheCheckedIn(ctx) {
+			removeCache = rc.CacheFolder()
+		}
+		if yarn, err = nodejs.InstallYarnBerryDependencies(ctx, berryCacheLayer, nodeEnv); err != nil {
+			return err
+		}
+	} else if err := installClassicDependencies(ctx, nodeEnv); err != nil {
+		return err
+	}
+	// Registry credentials are only available while installing dependencies, not to build scripts.
+	removeAuth()
+
+	// In a workspace monorepo, only the workspace selected by GOOGLE_BUILDABLE and its dependencies are built.
+	wss, err := nodejs.BuildableWorkspaces(ctx)
+	if err != nil {
+		return err
+	}
+	if len(wss) > 0 {
+		nodejs.BuildWorkspaces(ctx, wss, append(yarn, "run"))
+	} else {
+		pjs, err := nodejs.ReadPackageJSON(ctx.ApplicationRoot())
+		if err != nil {
+			return fmt.Errorf("reading package.json: %w", err)
+		}
+		if fw := nodejs.DetectFramework(pjs); fw != nil {
+			nodejs.BuildFramework(ctx, fw, pjs, append(yarn, "run"), append(yarn, "run"))
+		} else {
+			for _, script := range pjs.BuildScripts() {
+				ctx.ExecUser(append(yarn, "run", script))
+			}
+		}
+	}
+	// Remove devDependencies, the next buildpack installs production dependencies for launch.
+	ctx.RemoveAll("node_modules")
+	if removeCache != "" {
+		ctx.RemoveAll(removeCache)
+	}
+	return nil
+}
+
+// installClassicDependencies installs dependencies with Yarn 1, restoring node_modules from the cache if package.json
+// and yarn.lock are unchanged.
+func installClassicDependencies(ctx *gcp.Context, nodeEnv string) error {
+	if err := nodejs.InstallYarn(ctx, layers.Cache); err != nil {
+		return fmt.Errorf("installing Yarn: %w", err)
+	}
+
+	l := ctx.Layer("yarn")
+	nm := filepath.Join(l.Root, "node_modules")
+	nodejs.CacheNodeGypHeaders(ctx)
 	cached, meta, err := nodejs.CheckCache(ctx, l, cache.WithStrings(nodeEnv), cache.WithFiles("package.json", nodejs.YarnLock))
 	if err != nil {
 		return fmt.Errorf("checking cache: %w", err)
@@ -85,10 +160,10 @@ func buildFn(ctx *gcp.Context) error {
 		// Ensure node_modules exists even if no dependencies were installed.
 		ctx.MkdirAll("node_modules", 0755)
 		ctx.Exec([]string{"cp", "--archive", "node_modules", nm})
+		if err := nodejs.RecordNativeAddons(ctx, meta); err != nil {
+			return err
+		}
 	}
-
-	ctx.ExecUser([]string{"yarn", "run", "gcp-build"})
-	ctx.RemoveAll("node_modules")
 	ctx.WriteMetadata(l, &meta, layers.Cache)
 	return nil
 }
@@ -88,6 +88,14 @@ func TestDetect(t *testing.T) {
 			},
 			want: 0,
 		},
+		{
+			name: "with package with next without build scripts",
+			files: map[string]string{
+				"yarn.lock":    "",
+				"package.json": `{"dependencies": {"next": "^10.0.0"}}`,
+			},
+			want: 0,
+		},
 	}
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -95,3 +103,80 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestDetectWorkspaces(t *testing.T) {
+	files := map[string]string{
+		"yarn.lock":                    "",
+		"package.json":                 `{"workspaces": ["packages/*"], "scripts": {"gcp-build": "tsc -b"}}`,
+		"packages/api/package.json":    `{"name": "api", "dependencies": {"lib": "*"}}`,
+		"packages/lib/package.json":    `{"name": "lib", "scripts": {"gcp-build": "tsc -p ."}}`,
+		"packages/static/package.json": `{"name": "static"}`,
+	}
+	testCases := []struct {
+		name string
+		env  []string
+		want int
+	}{
+		{
+			name: "dependency with gcp_build",
+			env:  []string{"GOOGLE_BUILDABLE=packages/api"},
+			want: 0,
+		},
+		{
+			name: "workspace without gcp_build",
+			env:  []string{"GOOGLE_BUILDABLE=static"},
+			want: 100,
+		},
+		{
+			name: "unknown workspace",
+			env:  []string{"GOOGLE_BUILDABLE=packages/missing"},
+			want: 1,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, files, tc.env, tc.want)
+		})
+	}
+}
+
+func TestDetectBuildScripts(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		env   []string
+		want  int
+	}{
+		{
+			name: "build script",
+			files: map[string]string{
+				"yarn.lock":    "",
+				"package.json": `{"scripts": {"build": "webpack"}}`,
+			},
+			want: 0,
+		},
+		{
+			name: "scripts from env",
+			files: map[string]string{
+				"yarn.lock":    "",
+				"package.json": `{"scripts": {"compile": "tsc"}}`,
+			},
+			env:  []string{"GOOGLE_NODE_RUN_SCRIPTS=lint,compile"},
+			want: 0,
+		},
+		{
+			name: "scripts from env not defined",
+			files: map[string]string{
+				"yarn.lock":    "",
+				"package.json": `{"scripts": {"gcp-build": "tsc"}}`,
+			},
+			env:  []string{"GOOGLE_NODE_RUN_SCRIPTS=compile"},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, tc.env, tc.want)
+		})
+	}
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements php/runtime buildpack.
+// The runtime buildpack installs the PHP runtime and Composer.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/php"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpackplan"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	phpLayer        = "php"
+	phpURL          = "https://storage.googleapis.com/gcp-buildpacks/php/php-%s.tar.gz"
+	composerURL     = "https://getcomposer.org/download/%s/composer.phar"
+	composerVersion = "1.10.17"
+)
+
+var (
+	// stabilityFlagRegexp matches a Composer stability flag, e.g. `@dev`.
+	stabilityFlagRegexp = regexp.MustCompile(`@\w+`)
+)
+
+// metadata represents metadata stored for a runtime layer.
+type metadata struct {
+	Version         string `toml:"version"`
+	ComposerVersion string `toml:"composer_version"`
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	runtime.CheckOverride(ctx, "php")
+
+	// Only top-level files are considered: PHP sources are common in other projects, e.g. in node_modules.
+	if ctx.FileExists("composer.json") || ctx.FileExists("public", "index.php") {
+		return nil
+	}
+	if len(ctx.Glob("*.php")) == 0 {
+		ctx.OptOut("No composer.json, public/index.php or *.php files found at the application root.")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	version, err := runtimeVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	var meta metadata
+	l := ctx.Layer(phpLayer)
+	ctx.ReadMetadata(l, &meta)
+	if version == meta.Version && composerVersion == meta.ComposerVersion {
+		ctx.CacheHit(phpLayer)
+		ctx.Logf("Runtime cache hit, skipping installation.")
+		return nil
+	}
+	ctx.CacheMiss(phpLayer)
+	if err := runtime.InstallVersion(ctx, l, version, func(dir string) error {
+		archiveURL := fmt.Sprintf(phpURL, version)
+		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		}
+
+		ctx.Logf("Installing PHP v%s", version)
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, dir)
+		ctx.Exec([]string{"bash", "-c", command})
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	// php-fpm is installed in sbin, which is not added to PATH.
+	bin := filepath.Join(l.Root, "bin")
+	if fpm := filepath.Join(l.Root, "sbin", "php-fpm"); ctx.FileExists(fpm) {
+		ctx.Symlink(fpm, filepath.Join(bin, "php-fpm"))
+	}
+
+	ctx.Logf("Installing Composer v%s", composerVersion)
+	composer := filepath.Join(bin, "composer")
+	ctx.Exec([]string{"curl", "--fail", "--show-error", "--silent", "--location", "--retry", "3", "--output", composer, fmt.Sprintf(composerURL, composerVersion)})
+	ctx.Exec([]string{"chmod", "+x", composer})
+
+	meta.Version = version
+	meta.ComposerVersion = composerVersion
+	ctx.WriteMetadata(l, meta, layers.Build, layers.Cache, layers.Launch)
+
+	ctx.AddBuildpackPlan(buildpackplan.Plan{
+		Name:    phpLayer,
+		Version: version,
+	})
+	return nil
+}
+
+// runtimeVersion returns the version of PHP to install, from the env var or the `php` requirement in composer.json,
+// or the latest release.
+func runtimeVersion(ctx *gcp.Context) (string, error) {
+	constraints, err := versionConstraints(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runtime.ResolveFirst(ctx, runtime.PHP, constraints)
+}
+
+// versionConstraints returns all PHP version constraints specified for the application, in order of precedence.
+func versionConstraints(ctx *gcp.Context) ([]runtime.Constraint, error) {
+	var constraints []runtime.Constraint
+	if c, ok := runtime.EnvConstraint(); ok {
+		constraints = append(constraints, c)
+	}
+	if ctx.FileExists(filepath.Join(ctx.ApplicationRoot(), "composer.json")) {
+		cjs, err := php.ReadComposerJSON(ctx.ApplicationRoot())
+		if err != nil {
+			return nil, err
+		}
+		if v := strings.TrimSpace(cjs.Require["php"]); v != "" {
+			constraints = append(constraints, runtime.Constraint{Value: composerRange(v), Source: "composer.json"})
+		}
+	}
+	return constraints, nil
+}
+
+// composerRange converts a Composer version constraint, e.g. `^7.3|^8.0` or `>=7.2,<8`, to a semver range.
+func composerRange(constraint string) string {
+	constraint = stabilityFlagRegexp.ReplaceAllString(constraint, "")
+	var alternatives []string
+	for _, alt := range strings.Split(strings.Replace(constraint, "||", "|", -1), "|") {
+		var comparators []string
+		for _, c := range strings.Fields(strings.Replace(alt, ",", " ", -1)) {
+			if strings.HasPrefix(c, "~") {
+				// A tilde constraint allows the last specified part to increase, e.g. `~7.2` is `>=7.2` and `<8`.
+				v := strings.TrimPrefix(c, "~")
+				parts := strings.Split(v, ".")
+				comparators = append(comparators, ">="+v)
+				if len(parts) > 1 {
+					comparators = append(comparators, strings.Join(parts[:len(parts)-1], ".")+".x")
+				}
+				continue
+			}
+			comparators = append(comparators, c)
+		}
+		if len(comparators) > 0 {
+			alternatives = append(alternatives, strings.Join(comparators, " "))
+		}
+	}
+	return strings.Join(alternatives, " || ")
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "composer.json",
+			files: map[string]string{
+				"composer.json": "",
+			},
+			want: 0,
+		},
+		{
+			name: "php files",
+			files: map[string]string{
+				"index.php": "",
+			},
+			want: 0,
+		},
+		{
+			name: "document root",
+			files: map[string]string{
+				"public/index.php": "",
+			},
+			want: 0,
+		},
+		{
+			name:  "no php files",
+			files: map[string]string{},
+			want:  100,
+		},
+		{
+			name: "nested php files",
+			files: map[string]string{
+				"package.json":                 "",
+				"node_modules/dep/bin/run.php": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestVersionConstraints(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []runtime.Constraint
+	}{
+		{
+			name:  "none",
+			files: map[string]string{"index.php": ""},
+		},
+		{
+			name:  "composer.json without php",
+			files: map[string]string{"composer.json": `{"require": {"monolog/monolog": "^2.0"}}`},
+		},
+		{
+			name:  "composer.json",
+			files: map[string]string{"composer.json": `{"require": {"php": ">=7.3,<8"}}`},
+			want:  []runtime.Constraint{{Value: ">=7.3 <8", Source: "composer.json"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("Creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for f, c := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("Writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := versionConstraints(ctx)
+			if err != nil {
+				t.Fatalf("versionConstraints() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("versionConstraints() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestComposerRange(t *testing.T) {
+	testCases := []struct {
+		constraint string
+		want       string
+	}{
+		{constraint: "7.4.10", want: "7.4.10"},
+		{constraint: "^7.3", want: "^7.3"},
+		{constraint: "7.4.*", want: "7.4.*"},
+		{constraint: "~7.2", want: ">=7.2 7.x"},
+		{constraint: "~7.4.1", want: ">=7.4.1 7.4.x"},
+		{constraint: ">=7.2,<8.0", want: ">=7.2 <8.0"},
+		{constraint: ">=7.2 <8.0", want: ">=7.2 <8.0"},
+		{constraint: "^7.3|^8.0", want: "^7.3 || ^8.0"},
+		{constraint: "^7.3 || ^8.0", want: "^7.3 || ^8.0"},
+		{constraint: "^7.4@dev", want: "^7.4"},
+	}
+	for _, tc := range testCases {
+		got := composerRange(tc.constraint)
+		if got != tc.want {
+			t.Errorf("composerRange(%q) = %q, want %q", tc.constraint, got, tc.want)
+		}
+		if _, err := runtime.ParseRange(got); err != nil {
+			t.Errorf("runtime.ParseRange(%q) got error: %v", got, err)
+		}
+	}
+}
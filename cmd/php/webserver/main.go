@@ -0,0 +1,184 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements php/webserver buildpack.
+// The webserver buildpack serves the application with nginx and php-fpm or with PHP's built-in web server.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	nginxLayer   = "nginx"
+	configLayer  = "config"
+	nginxURL     = "https://storage.googleapis.com/gcp-buildpacks/nginx/nginx-%s.tar.gz"
+	nginxVersion = "1.18.0"
+	fpmSocket    = "/tmp/php-fpm.sock"
+
+	nginxServer   = "nginx"
+	builtinServer = "builtin"
+
+	defaultDocumentRoot = "public"
+)
+
+var (
+	nginxConfTmpl = template.Must(template.New("nginx.conf").Parse(nginxConfTemplate))
+	fpmConfTmpl   = template.Must(template.New("php-fpm.conf").Parse(fpmConfTemplate))
+	startTmpl     = template.Must(template.New("start").Parse(startTemplate))
+)
+
+// metadata represents metadata stored for the nginx layer.
+type metadata struct {
+	Version string `toml:"version"`
+}
+
+// serverConfig holds the values substituted into the configuration templates.
+type serverConfig struct {
+	DocumentRoot string
+	NginxRoot    string
+	ConfigRoot   string
+	FPMSocket    string
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	// Always opt in.
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	server, err := webServer()
+	if err != nil {
+		return err
+	}
+	docRoot, err := documentRoot(ctx)
+	if err != nil {
+		return err
+	}
+	ctx.Logf("Serving %s with %s", docRoot, server)
+
+	if server == builtinServer {
+		ctx.AddWebProcess([]string{"/bin/bash", "-c", fmt.Sprintf("exec php -S 0.0.0.0:${PORT:-8080} -t %s", docRoot)})
+		return nil
+	}
+
+	nl, err := installNginx(ctx)
+	if err != nil {
+		return err
+	}
+	cl := ctx.Layer(configLayer)
+	ctx.ClearLayer(cl)
+	cfg := serverConfig{
+		DocumentRoot: docRoot,
+		NginxRoot:    nl.Root,
+		ConfigRoot:   cl.Root,
+		FPMSocket:    fpmSocket,
+	}
+	if err := writeTemplate(ctx, nginxConfTmpl, filepath.Join(cl.Root, "nginx.conf"), cfg, 0644); err != nil {
+		return err
+	}
+	if err := writeTemplate(ctx, fpmConfTmpl, filepath.Join(cl.Root, "php-fpm.conf"), cfg, 0644); err != nil {
+		return err
+	}
+	start := filepath.Join(cl.Root, "start")
+	if err := writeTemplate(ctx, startTmpl, start, cfg, 0755); err != nil {
+		return err
+	}
+	ctx.WriteMetadata(cl, nil, layers.Launch)
+
+	ctx.AddWebProcess([]string{start})
+	return nil
+}
+
+// webServer returns the web server selected by GOOGLE_PHP_WEBSERVER, nginx by default.
+func webServer() (string, error) {
+	switch s := strings.ToLower(strings.TrimSpace(os.Getenv(env.PHPWebServer))); s {
+	case "", nginxServer:
+		return nginxServer, nil
+	case builtinServer:
+		return builtinServer, nil
+	default:
+		return "", gcp.UserErrorf("invalid %s %q, must be %q or %q", env.PHPWebServer, s, nginxServer, builtinServer)
+	}
+}
+
+// documentRoot returns the absolute path of the directory to serve, from GOOGLE_PHP_DOCUMENT_ROOT or the `public`
+// directory if it exists, otherwise the application root.
+func documentRoot(ctx *gcp.Context) (string, error) {
+	root := ctx.ApplicationRoot()
+	dr, ok := os.LookupEnv(env.PHPDocumentRoot)
+	if !ok {
+		if ctx.FileExists(root, defaultDocumentRoot) {
+			return filepath.Join(root, defaultDocumentRoot), nil
+		}
+		return root, nil
+	}
+	rel := filepath.Clean(strings.TrimSpace(dr))
+	if filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", gcp.UserErrorf("%s must be a directory within the application, got %q", env.PHPDocumentRoot, dr)
+	}
+	path := filepath.Join(root, rel)
+	if fi, err := os.Stat(path); err != nil || !fi.IsDir() {
+		return "", gcp.UserErrorf("%s %q is not a directory", env.PHPDocumentRoot, dr)
+	}
+	return path, nil
+}
+
+// installNginx installs nginx in a launch layer.
+func installNginx(ctx *gcp.Context) (*layers.Layer, error) {
+	var meta metadata
+	l := ctx.Layer(nginxLayer)
+	ctx.ReadMetadata(l, &meta)
+	if meta.Version == nginxVersion {
+		ctx.CacheHit(nginxLayer)
+		return l, nil
+	}
+	ctx.CacheMiss(nginxLayer)
+	ctx.ClearLayer(l)
+
+	archiveURL := fmt.Sprintf(nginxURL, nginxVersion)
+	if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+		return nil, gcp.InternalErrorf("nginx %s does not exist at %s (status %d)", nginxVersion, archiveURL, code)
+	}
+	ctx.Logf("Installing nginx v%s", nginxVersion)
+	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, l.Root)
+	ctx.Exec([]string{"bash", "-c", command})
+
+	meta.Version = nginxVersion
+	ctx.WriteMetadata(l, meta, layers.Cache, layers.Launch)
+	return l, nil
+}
+
+func writeTemplate(ctx *gcp.Context, tmpl *template.Template, path string, cfg serverConfig, perm os.FileMode) error {
+	var b bytes.Buffer
+	if err := tmpl.Execute(&b, cfg); err != nil {
+		return fmt.Errorf("executing template %s: %v", tmpl.Name(), err)
+	}
+	ctx.WriteFile(path, b.Bytes(), perm)
+	return nil
+}
@@ -0,0 +1,206 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			// The buildpack always opts in.
+			name:  "no files",
+			files: map[string]string{},
+			want:  0,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestWebServer(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{value: "", want: nginxServer},
+		{value: "nginx", want: nginxServer},
+		{value: "Builtin", want: builtinServer},
+		{value: "apache", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			setEnv(t, env.PHPWebServer, tc.value)
+
+			got, err := webServer()
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("webServer() got error %v, want error %t", err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("webServer() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestDocumentRoot(t *testing.T) {
+	testCases := []struct {
+		name    string
+		dirs    []string
+		env     *string
+		want    string
+		wantErr bool
+	}{
+		{
+			name: "application root",
+			want: ".",
+		},
+		{
+			name: "public",
+			dirs: []string{"public"},
+			want: "public",
+		},
+		{
+			name: "from env",
+			dirs: []string{"public", "web"},
+			env:  strPtr("web/"),
+			want: "web",
+		},
+		{
+			name: "env set to application root",
+			dirs: []string{"public"},
+			env:  strPtr("."),
+			want: ".",
+		},
+		{
+			name:    "env outside application",
+			env:     strPtr("../web"),
+			wantErr: true,
+		},
+		{
+			name:    "env absolute",
+			env:     strPtr("/var/www"),
+			wantErr: true,
+		},
+		{
+			name:    "env missing directory",
+			env:     strPtr("web"),
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("Creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, d := range tc.dirs {
+				if err := os.MkdirAll(filepath.Join(dir, d), 0755); err != nil {
+					t.Fatalf("Creating %s: %v", d, err)
+				}
+			}
+			if tc.env != nil {
+				setEnv(t, env.PHPDocumentRoot, *tc.env)
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := documentRoot(ctx)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("documentRoot() got error %v, want error %t", err, tc.wantErr)
+			}
+			if tc.wantErr {
+				return
+			}
+			if want := filepath.Join(dir, tc.want); got != want {
+				t.Errorf("documentRoot() = %q, want %q", got, want)
+			}
+		})
+	}
+}
+
+func TestTemplates(t *testing.T) {
+	cfg := serverConfig{
+		DocumentRoot: "/workspace/public",
+		NginxRoot:    "/layers/nginx",
+		ConfigRoot:   "/layers/config",
+		FPMSocket:    fpmSocket,
+	}
+	testCases := []struct {
+		tmpl *template.Template
+		want []string
+	}{
+		{
+			tmpl: nginxConfTmpl,
+			want: []string{"root /workspace/public;", "include /layers/nginx/conf/mime.types;", "fastcgi_pass unix:/tmp/php-fpm.sock;", "listen @PORT@;"},
+		},
+		{
+			tmpl: fpmConfTmpl,
+			want: []string{"listen = /tmp/php-fpm.sock"},
+		},
+		{
+			tmpl: startTmpl,
+			want: []string{"/layers/config/nginx.conf > /tmp/nginx.conf", "--fpm-config /layers/config/php-fpm.conf", "exec /layers/nginx/sbin/nginx"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.tmpl.Name(), func(t *testing.T) {
+			var b bytes.Buffer
+			if err := tc.tmpl.Execute(&b, cfg); err != nil {
+				t.Fatalf("Executing template: %v", err)
+			}
+			for _, w := range tc.want {
+				if !strings.Contains(b.String(), w) {
+					t.Errorf("%s does not contain %q:\n%s", tc.tmpl.Name(), w, b.String())
+				}
+			}
+		})
+	}
+}
+
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("Setting %s: %v", key, err)
+	}
+	t.Cleanup(func() {
+		if err := os.Unsetenv(key); err != nil {
+			t.Fatalf("Unsetting %s: %v", key, err)
+		}
+	})
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,84 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+// nginxConfTemplate configures nginx to serve static files from the document root and pass PHP scripts to php-fpm.
+// Requests for files that do not exist are routed to index.php, as expected by front-controller frameworks.
+// The listen port is substituted at launch time by the start script, as nginx does not read environment variables.
+const nginxConfTemplate = `daemon off;
+pid /tmp/nginx.pid;
+worker_processes auto;
+error_log stderr;
+
+events {
+  worker_connections 1024;
+}
+
+http {
+  include {{.NginxRoot}}/conf/mime.types;
+  default_type application/octet-stream;
+  access_log /dev/stdout;
+  sendfile on;
+  server_tokens off;
+
+  client_body_temp_path /tmp/nginx-client-body;
+  fastcgi_temp_path /tmp/nginx-fastcgi;
+  proxy_temp_path /tmp/nginx-proxy;
+  scgi_temp_path /tmp/nginx-scgi;
+  uwsgi_temp_path /tmp/nginx-uwsgi;
+
+  server {
+    listen @PORT@;
+    root {{.DocumentRoot}};
+    index index.php index.html;
+
+    location / {
+      try_files $uri $uri/ /index.php$is_args$args;
+    }
+
+    location ~ \.php$ {
+      try_files $uri =404;
+      include {{.NginxRoot}}/conf/fastcgi_params;
+      fastcgi_param SCRIPT_FILENAME $document_root$fastcgi_script_name;
+      fastcgi_pass unix:{{.FPMSocket}};
+    }
+  }
+}
+`
+
+// fpmConfTemplate configures a single php-fpm pool listening on a unix socket.
+const fpmConfTemplate = `[global]
+pid = /tmp/php-fpm.pid
+error_log = /proc/self/fd/2
+daemonize = no
+
+[app]
+listen = {{.FPMSocket}}
+pm = dynamic
+pm.max_children = 10
+pm.start_servers = 2
+pm.min_spare_servers = 1
+pm.max_spare_servers = 3
+clear_env = no
+catch_workers_output = yes
+`
+
+// startTemplate starts php-fpm in the background and nginx in the foreground.
+const startTemplate = `#!/bin/bash
+set -e
+sed "s/@PORT@/${PORT:-8080}/" {{.ConfigRoot}}/nginx.conf > /tmp/nginx.conf
+php-fpm --fpm-config {{.ConfigRoot}}/php-fpm.conf &
+exec {{.NginxRoot}}/sbin/nginx -c /tmp/nginx.conf
+`
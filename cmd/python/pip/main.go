@@ -18,7 +18,6 @@ package main
 
 import (
 	"fmt"
-	"os"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
@@ -60,19 +59,8 @@ func buildFn(ctx *gcp.Context) error {
 	ctx.CacheMiss(layerName)
 
 	// Install modules in requirements.txt.
-	ctx.Logf("Running pip install.")
-	ctx.ExecUser([]string{"python3", "-m", "pip", "install", "--upgrade", "-r", "requirements.txt", "-t", l.Root})
-
-	ctx.PrependPathSharedEnv(l, "PYTHONPATH", l.Root)
-
-	// Check for broken dependencies.
-	ctx.Logf("Checking for incompatible dependencies.")
-	checkDeps := ctx.ExecWithParams(gcp.ExecParams{
-		Cmd: []string{"python3", "-m", "pip", "check"},
-		Env: []string{"PYTHONPATH=" + l.Root + ":" + os.Getenv("PYTHONPATH")},
-	})
-	if checkDeps.ExitCode != 0 {
-		return fmt.Errorf("incompatible dependencies installed: %q", checkDeps.Stdout)
+	if err := python.InstallRequirements(ctx, l, "requirements.txt"); err != nil {
+		return err
 	}
 
 	ctx.WriteMetadata(l, &meta, layers.Build, layers.Cache, layers.Launch)
@@ -0,0 +1,95 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/pipenv buildpack.
+// The pipenv buildpack installs dependencies locked with Pipenv.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	pipenvLayer = "pipenv"
+	depsLayer   = "pipenv_dependencies"
+	pipfile     = "Pipfile"
+	pipfileLock = "Pipfile.lock"
+	// defaultPipenvVersion is the version of Pipenv used to install the lock file.
+	defaultPipenvVersion = "2020.11.15"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(pipfile) {
+		ctx.OptOut("%s not found", pipfile)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(pipfileLock) {
+		return gcp.UserErrorf("%s not found, run `pipenv lock` and commit %s to install dependencies deterministically", pipfileLock, pipfileLock)
+	}
+
+	// Pipfile is part of the key so that a lock file out of date with it is always reported.
+	l := ctx.Layer(depsLayer)
+	cached, meta, err := python.CheckCache(ctx, l, cache.WithFiles(pipfile, pipfileLock))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		ctx.CacheHit(depsLayer)
+		return nil
+	}
+	ctx.CacheMiss(depsLayer)
+	ctx.ClearLayer(l)
+
+	pipenv := python.InstallTool(ctx, pipenvLayer, "pipenv", defaultPipenvVersion)
+
+	// Dependencies are installed in a virtual environment in the layer, which is activated through PATH.
+	ctx.Exec([]string{"python3", "-m", "venv", l.Root})
+	ctx.Logf("Running pipenv install --deploy.")
+	result, err := ctx.ExecWithErrWithParams(gcp.ExecParams{
+		Cmd: []string{pipenv, "install", "--deploy"},
+		Env: []string{"VIRTUAL_ENV=" + l.Root, "PIPENV_VERBOSITY=-1", "PIPENV_NOSPIN=1"},
+	})
+	if err != nil {
+		if result == nil {
+			return gcp.InternalErrorf("running pipenv install: %v", err)
+		}
+		if isLockOutOfDate(result.Combined) {
+			return gcp.UserErrorf("%s is out of date with %s, run `pipenv lock` and commit the updated %s", pipfileLock, pipfile, pipfileLock)
+		}
+		return gcp.UserErrorf("installing dependencies with pipenv: %s", result.Stderr)
+	}
+
+	ctx.PrependPathSharedEnv(l, "PATH", filepath.Join(l.Root, "bin"))
+	ctx.WriteMetadata(l, meta, layers.Build, layers.Cache, layers.Launch)
+	return nil
+}
+
+// isLockOutOfDate returns true if pipenv output reports that Pipfile.lock does not match the Pipfile hash.
+func isLockOutOfDate(output string) bool {
+	return strings.Contains(output, pipfileLock) && strings.Contains(output, "out of date")
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "pipfile and lock",
+			files: map[string]string{
+				"main.py":      "",
+				"Pipfile":      "",
+				"Pipfile.lock": "",
+			},
+			want: 0,
+		},
+		{
+			name: "pipfile without lock",
+			files: map[string]string{
+				"main.py": "",
+				"Pipfile": "",
+			},
+			want: 0,
+		},
+		{
+			name: "requirements file",
+			files: map[string]string{
+				"main.py":          "",
+				"requirements.txt": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestIsLockOutOfDate(t *testing.T) {
+	testCases := []struct {
+		name   string
+		output string
+		want   bool
+	}{
+		{
+			name:   "out of date",
+			output: "Your Pipfile.lock (4b9e63) is out of date. Expected: (8c2a1f).\n[DeployException]: Aborting deploy\n",
+			want:   true,
+		},
+		{
+			name:   "other error",
+			output: "ERROR: Could not find a version that satisfies the requirement flask==99.0\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := isLockOutOfDate(tc.output); got != tc.want {
+				t.Errorf("isLockOutOfDate(%q) = %t, want %t", tc.output, got, tc.want)
+			}
+		})
+	}
+}
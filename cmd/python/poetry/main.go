@@ -0,0 +1,79 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements python/poetry buildpack.
+// The poetry buildpack installs dependencies locked with Poetry.
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/python"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	poetryLayer = "poetry"
+	depsLayer   = "poetry_dependencies"
+	poetryLock  = "poetry.lock"
+	pyproject   = "pyproject.toml"
+	// defaultPoetryVersion is the version of Poetry used to export the lock file.
+	defaultPoetryVersion = "1.1.4"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if !ctx.FileExists(pyproject) {
+		ctx.OptOut("%s not found", pyproject)
+	}
+	if !ctx.FileExists(poetryLock) {
+		ctx.OptOut("%s not found", poetryLock)
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	l := ctx.Layer(depsLayer)
+	cached, meta, err := python.CheckCache(ctx, l, cache.WithFiles(poetryLock))
+	if err != nil {
+		return fmt.Errorf("checking cache: %w", err)
+	}
+	if cached {
+		ctx.CacheHit(depsLayer)
+		return nil
+	}
+	ctx.CacheMiss(depsLayer)
+	ctx.ClearLayer(l)
+
+	poetry := python.InstallTool(ctx, poetryLayer, "poetry", defaultPoetryVersion)
+
+	// Export the main dependencies, without dev-dependencies, pinned with hashes from the lock file.
+	tmp := ctx.TempDir("", "poetry-")
+	defer ctx.RemoveAll(tmp)
+	req := filepath.Join(tmp, "requirements.txt")
+	ctx.ExecUser([]string{poetry, "export", "--format", "requirements.txt", "--output", req})
+
+	if err := python.InstallRequirements(ctx, l, req); err != nil {
+		return err
+	}
+
+	ctx.WriteMetadata(l, meta, layers.Build, layers.Cache, layers.Launch)
+	return nil
+}
@@ -19,10 +19,11 @@ package main
 import (
 	"fmt"
 	"net/http"
-	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 
+	"github.com/BurntSushi/toml"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
@@ -33,9 +34,11 @@ import (
 const (
 	pythonLayer = "python"
 	pythonURL   = "https://storage.googleapis.com/gcp-buildpacks/python/python-%s.tar.gz"
-	// TODO(b/148375706): Add mapping for stable/beta versions.
-	versionURL  = "https://storage.googleapis.com/gcp-buildpacks/python/latest.version"
 	versionFile = ".python-version"
+	runtimeTxt  = "runtime.txt"
+	pipfile     = "Pipfile"
+	// pyprojectTOML is the project metadata file, see https://www.python.org/dev/peps/pep-0621/.
+	pyprojectTOML = "pyproject.toml"
 )
 
 // metadata represents metadata stored for a runtime layer.
@@ -70,17 +73,21 @@ func buildFn(ctx *gcp.Context) error {
 		return nil
 	}
 	ctx.CacheMiss(pythonLayer)
-	ctx.ClearLayer(l)
+	if err := runtime.InstallVersion(ctx, l, version, func(dir string) error {
+		archiveURL := fmt.Sprintf(pythonURL, version)
+		if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+			return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		}
 
-	archiveURL := fmt.Sprintf(pythonURL, version)
-	if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
-		return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+		ctx.Logf("Installing Python v%s", version)
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, dir)
+		ctx.Exec([]string{"bash", "-c", command})
+		return nil
+	}); err != nil {
+		return err
 	}
 
-	ctx.Logf("Installing Python v%s", version)
-	command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, l.Root)
-	ctx.Exec([]string{"bash", "-c", command})
-
+	// pip is upgraded in the layer rather than the version cache, as it writes scripts with the layer path.
 	ctx.Logf("Upgrading pip to the latest version and installing build tools")
 	path := filepath.Join(l.Root, "bin/python3")
 	ctx.Exec([]string{path, "-m", "pip", "install", "--upgrade", "pip", "setuptools", "wheel"})
@@ -95,21 +102,139 @@ func buildFn(ctx *gcp.Context) error {
 	return nil
 }
 
+// runtimeVersion returns the version of Python to install, from the first source that specifies one, or the latest
+// release. The sources are, in order of precedence, the env var, .python-version, runtime.txt, Pipfile and
+// pyproject.toml.
 func runtimeVersion(ctx *gcp.Context) (string, error) {
-	if v := os.Getenv(env.RuntimeVersion); v != "" {
-		ctx.Logf("Using runtime version from %s: %s", env.RuntimeVersion, v)
-		return v, nil
+	constraints, err := versionConstraints(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runtime.ResolveFirst(ctx, runtime.Python, constraints)
+}
+
+// versionConstraints returns all Python version constraints specified for the application, in order of precedence.
+func versionConstraints(ctx *gcp.Context) ([]runtime.Constraint, error) {
+	var constraints []runtime.Constraint
+	if c, ok := runtime.EnvConstraint(); ok {
+		constraints = append(constraints, c)
+	}
+
+	if path := filepath.Join(ctx.ApplicationRoot(), versionFile); ctx.FileExists(path) {
+		v := strings.TrimSpace(string(ctx.ReadFile(path)))
+		if v == "" {
+			return nil, gcp.UserErrorf("%s exists but does not specify a version", versionFile)
+		}
+		constraints = append(constraints, runtime.Constraint{Value: v, Source: versionFile})
+	}
+
+	if path := filepath.Join(ctx.ApplicationRoot(), runtimeTxt); ctx.FileExists(path) {
+		// runtime.txt holds a single line such as `python-3.8.5`.
+		v := strings.TrimSpace(string(ctx.ReadFile(path)))
+		if !strings.HasPrefix(v, "python-") {
+			return nil, gcp.UserErrorf("%s must specify the version as python-<version>, got %q", runtimeTxt, v)
+		}
+		constraints = append(constraints, runtime.Constraint{Value: strings.TrimPrefix(v, "python-"), Source: runtimeTxt})
 	}
-	if ctx.FileExists(versionFile) {
-		raw := ctx.ReadFile(versionFile)
-		v := strings.TrimSpace(string(raw))
-		if v != "" {
-			ctx.Logf("Using runtime version from %s: %s", versionFile, v)
-			return v, nil
+
+	if path := filepath.Join(ctx.ApplicationRoot(), pipfile); ctx.FileExists(path) {
+		var p struct {
+			Requires struct {
+				PythonVersion     string `toml:"python_version"`
+				PythonFullVersion string `toml:"python_full_version"`
+			} `toml:"requires"`
+		}
+		if _, err := toml.DecodeFile(path, &p); err != nil {
+			return nil, gcp.UserErrorf("parsing %s: %v", pipfile, err)
+		}
+		if v := p.Requires.PythonFullVersion; v != "" {
+			constraints = append(constraints, runtime.Constraint{Value: v, Source: pipfile})
+		} else if v := p.Requires.PythonVersion; v != "" {
+			constraints = append(constraints, runtime.Constraint{Value: v, Source: pipfile})
 		}
-		return "", gcp.UserErrorf("%s exists but does not specify a version", versionFile)
 	}
-	v := ctx.Exec([]string{"curl", "--silent", versionURL}).Stdout
-	ctx.Logf("Using latest runtime version: %s", v)
-	return v, nil
+
+	if path := filepath.Join(ctx.ApplicationRoot(), pyprojectTOML); ctx.FileExists(path) {
+		var p struct {
+			Project struct {
+				RequiresPython string `toml:"requires-python"`
+			} `toml:"project"`
+		}
+		if _, err := toml.DecodeFile(path, &p); err != nil {
+			return nil, gcp.UserErrorf("parsing %s: %v", pyprojectTOML, err)
+		}
+		if v := p.Project.RequiresPython; v != "" {
+			constraints = append(constraints, runtime.Constraint{Value: pep440Range(v), Source: pyprojectTOML})
+		}
+	}
+	return constraints, nil
+}
+
+// padVersion pads a version with zeros to three components, e.g. `3.7` is `3.7.0`.
+func padVersion(v string) string {
+	for n := len(strings.Split(v, ".")); n < 3; n++ {
+		v += ".0"
+	}
+	return v
+}
+
+// pep440Range converts a PEP 440 version specifier, e.g. `>=3.7,<3.9` or `~=3.8`, to a semver range.
+func pep440Range(spec string) string {
+	// Each alternative is a conjunction of comparators; excluding a wildcard version splits every alternative in two.
+	alternatives := [][]string{nil}
+	and := func(comparators ...string) {
+		for i := range alternatives {
+			alternatives[i] = append(alternatives[i], comparators...)
+		}
+	}
+	for _, c := range strings.Split(spec, ",") {
+		c = strings.TrimSpace(c)
+		switch {
+		case strings.HasPrefix(c, "~="):
+			// A compatible release, e.g. `~=3.8.1` is `>=3.8.1` and `==3.8.*`.
+			v := strings.TrimSpace(strings.TrimPrefix(c, "~="))
+			parts := strings.Split(v, ".")
+			and(">=" + v)
+			if len(parts) > 1 {
+				and(strings.Join(parts[:len(parts)-1], ".") + ".x")
+			}
+		case strings.HasPrefix(c, "!="):
+			v := strings.TrimSpace(strings.TrimPrefix(c, "!="))
+			if !strings.HasSuffix(v, ".*") {
+				// Versions are padded with zeros, e.g. `!=3.7` excludes only 3.7.0.
+				and("!=" + padVersion(v))
+				continue
+			}
+			// An excluded prefix, e.g. `!=3.7.*` is `<3.7 || >=3.8`.
+			parts := strings.Split(strings.TrimSuffix(v, ".*"), ".")
+			lower := "<" + strings.Join(parts, ".")
+			if n, err := strconv.Atoi(parts[len(parts)-1]); err == nil {
+				parts[len(parts)-1] = strconv.Itoa(n + 1)
+			}
+			upper := ">=" + strings.Join(parts, ".")
+			var split [][]string
+			for _, a := range alternatives {
+				split = append(split, append(append([]string(nil), a...), lower), append(append([]string(nil), a...), upper))
+			}
+			alternatives = split
+		case strings.HasPrefix(c, ">") || strings.HasPrefix(c, "<"):
+			// Unlike semver ranges, PEP 440 compares the padded version, e.g. `>3.7` is `>3.7.0` and matches 3.7.9.
+			op := c[:1]
+			if strings.HasPrefix(c[1:], "=") {
+				op = c[:2]
+			}
+			and(op + padVersion(strings.TrimSpace(strings.TrimPrefix(c, op))))
+		case strings.HasPrefix(c, "==="):
+			and(strings.TrimSpace(strings.TrimPrefix(c, "===")))
+		case strings.HasPrefix(c, "=="):
+			and(strings.TrimSpace(strings.TrimPrefix(c, "==")))
+		case c != "":
+			and(c)
+		}
+	}
+	var ranges []string
+	for _, a := range alternatives {
+		ranges = append(ranges, strings.Join(a, " "))
+	}
+	return strings.Join(ranges, " || ")
 }
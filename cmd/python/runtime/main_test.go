@@ -15,9 +15,15 @@
 package main
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
 	"testing"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
 )
 
 func TestDetect(t *testing.T) {
@@ -45,3 +51,168 @@ func TestDetect(t *testing.T) {
 		})
 	}
 }
+
+func TestVersionConstraints(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []runtime.Constraint
+	}{
+		{
+			name:  "none",
+			files: map[string]string{"main.py": ""},
+		},
+		{
+			name:  "runtime.txt",
+			files: map[string]string{"runtime.txt": "python-3.8.5\n"},
+			want:  []runtime.Constraint{{Value: "3.8.5", Source: "runtime.txt"}},
+		},
+		{
+			name:  "Pipfile python_version",
+			files: map[string]string{"Pipfile": "[requires]\npython_version = \"3.8\"\n"},
+			want:  []runtime.Constraint{{Value: "3.8", Source: "Pipfile"}},
+		},
+		{
+			name:  "Pipfile python_full_version",
+			files: map[string]string{"Pipfile": "[requires]\npython_version = \"3.8\"\npython_full_version = \"3.8.5\"\n"},
+			want:  []runtime.Constraint{{Value: "3.8.5", Source: "Pipfile"}},
+		},
+		{
+			name:  "pyproject.toml",
+			files: map[string]string{"pyproject.toml": "[project]\nname = \"app\"\nrequires-python = \">=3.7,<3.9\"\n"},
+			want:  []runtime.Constraint{{Value: ">=3.7.0 <3.9.0", Source: "pyproject.toml"}},
+		},
+		{
+			name: "all sources in order",
+			files: map[string]string{
+				".python-version": "3.8.6",
+				"runtime.txt":     "python-3.8.5",
+				"Pipfile":         "[requires]\npython_version = \"3.7\"\n",
+				"pyproject.toml":  "[project]\nrequires-python = \"~=3.7\"\n",
+			},
+			want: []runtime.Constraint{
+				{Value: "3.8.6", Source: ".python-version"},
+				{Value: "3.8.5", Source: "runtime.txt"},
+				{Value: "3.7", Source: "Pipfile"},
+				{Value: ">=3.7 3.x", Source: "pyproject.toml"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := versionConstraints(ctx)
+			if err != nil {
+				t.Fatalf("versionConstraints() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("versionConstraints() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestPEP440Range(t *testing.T) {
+	testCases := []struct {
+		spec string
+		want string
+	}{
+		{spec: ">=3.7", want: ">=3.7.0"},
+		{spec: ">3.7", want: ">3.7.0"},
+		{spec: "<=3.8", want: "<=3.8.0"},
+		{spec: "> 3.7", want: ">3.7.0"},
+		{spec: ">=3.7, <3.9", want: ">=3.7.0 <3.9.0"},
+		{spec: "~=3.8", want: ">=3.8 3.x"},
+		{spec: "~=3.8.1", want: ">=3.8.1 3.8.x"},
+		{spec: "==3.8.*", want: "3.8.*"},
+		{spec: "===3.8.5", want: "3.8.5"},
+		{spec: ">=3.6,!=3.7.0", want: ">=3.6.0 !=3.7.0"},
+		{spec: ">=3.6,!=3.7", want: ">=3.6.0 !=3.7.0"},
+		{spec: ">=3.6,!=3.7.*", want: ">=3.6.0 <3.7 || >=3.6.0 >=3.8"},
+		{spec: "!=3.6.*,!=3.7.*", want: "<3.6 <3.7 || <3.6 >=3.8 || >=3.7 <3.7 || >=3.7 >=3.8"},
+	}
+	for _, tc := range testCases {
+		if got := pep440Range(tc.spec); got != tc.want {
+			t.Errorf("pep440Range(%q) = %q, want %q", tc.spec, got, tc.want)
+		}
+	}
+}
+
+func TestRuntimeVersion(t *testing.T) {
+	defer runtime.RegisterIndex(runtime.Python, runtime.StaticIndex{
+		{Version: "3.7.9"},
+		{Version: "3.8.5"},
+		{Version: "3.8.6"},
+		{Version: "3.9.0"},
+	})()
+
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name:  "latest",
+			files: map[string]string{"main.py": ""},
+			want:  "3.9.0",
+		},
+		{
+			name:  "partial version",
+			files: map[string]string{"runtime.txt": "python-3.8"},
+			want:  "3.8.6",
+		},
+		{
+			name:  "requires-python",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.7,<3.9\"\n"},
+			want:  "3.8.6",
+		},
+		{
+			name:  "requires-python exclusive partial minimum",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">3.7,<3.8\"\n"},
+			want:  "3.7.9",
+		},
+		{
+			name:  "requires-python inclusive partial maximum",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \"<=3.8\"\n"},
+			want:  "3.7.9",
+		},
+		{
+			name:  "requires-python excluding a minor version",
+			files: map[string]string{"pyproject.toml": "[project]\nrequires-python = \">=3.7,!=3.9.*,<4\"\n"},
+			want:  "3.8.6",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := runtimeVersion(ctx)
+			if err != nil {
+				t.Fatalf("runtimeVersion() got error: %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("runtimeVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+// writeApp creates a temporary application directory containing files.
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "app-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	for f, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	return dir
+}
@@ -22,15 +22,7 @@ import (
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/appengine"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
-)
-
-const (
-	bundleIndicator  = "Gemfile.lock"
-	bundle2Indicator = "gems.locked"
-	railsIndicator   = "bin/rails"
-	railsCommand     = "bin/rails server"
-	rackIndicator    = "config.ru"
-	rackCommand      = "rackup --port $PORT"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
 )
 
 func main() {
@@ -58,13 +50,9 @@ func buildFn(ctx *gcp.Context) error {
 }
 
 func entrypoint(ctx *gcp.Context, srcDir string) (*appengine.Entrypoint, error) {
-	var ep string
 	ctx.Logf("WARNING: No entrypoint specified. Attempting to infer entrypoint, but it is recommended to set an explicit `entrypoint` in app.yaml.")
-	if ctx.FileExists(srcDir, railsIndicator) {
-		ep = maybeBundle(ctx, srcDir, railsCommand)
-	} else if ctx.FileExists(srcDir, rackIndicator) {
-		ep = maybeBundle(ctx, srcDir, rackCommand)
-	} else {
+	ep, ok := ruby.InferEntrypoint(ctx, srcDir)
+	if !ok {
 		return nil, errors.New("unable to infer entrypoint, please set the `entrypoint` field in app.yaml: https://cloud.google.com/appengine/docs/standard/ruby/runtime#application_startup")
 	}
 	ctx.Logf("Using inferred entrypoint: %q", ep)
@@ -73,10 +61,3 @@ func entrypoint(ctx *gcp.Context, srcDir string) (*appengine.Entrypoint, error)
 		Command: ep,
 	}, nil
 }
-
-func maybeBundle(ctx *gcp.Context, srcDir, cmd string) string {
-	if ctx.FileExists(srcDir, bundleIndicator) || ctx.FileExists(srcDir, bundle2Indicator) {
-		return "bundle exec " + cmd
-	}
-	return cmd
-}
@@ -0,0 +1,45 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements ruby/entrypoint buildpack.
+// The entrypoint buildpack infers the entrypoint of Rails and Rack applications.
+package main
+
+import (
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/ruby"
+)
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	if _, ok := ruby.InferEntrypoint(ctx, ctx.ApplicationRoot()); !ok {
+		ctx.OptOut("Neither bin/rails nor config.ru found.")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	ep, ok := ruby.InferEntrypoint(ctx, ctx.ApplicationRoot())
+	if !ok {
+		return gcp.UserErrorf("unable to infer the entrypoint of a Ruby application without bin/rails or config.ru, please set %s or add a Procfile", env.Entrypoint)
+	}
+	ctx.Logf("Using inferred entrypoint: %s", ep)
+	// Use /bin/bash because lifecycle/launcher will assume the whole command is a single executable.
+	ctx.AddWebProcess([]string{"/bin/bash", "-c", ep})
+	return nil
+}
@@ -0,0 +1,231 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Implements ruby/runtime buildpack.
+// The runtime buildpack installs the Ruby runtime.
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpackplan"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	rubyLayer   = "ruby"
+	rubyURL     = "https://storage.googleapis.com/gcp-buildpacks/ruby/ruby-%s.tar.gz"
+	versionFile = ".ruby-version"
+)
+
+var (
+	// gemfiles are the Bundler manifests and their lock files, in order of precedence.
+	gemfiles = []struct{ manifest, lock string }{
+		{manifest: "Gemfile", lock: "Gemfile.lock"},
+		{manifest: "gems.rb", lock: "gems.locked"},
+	}
+
+	// rubyDirectiveRegexp matches a `ruby` directive with one or more requirements, e.g. `ruby '~> 2.7.0'`.
+	rubyDirectiveRegexp = regexp.MustCompile(`(?m)^\s*ruby[\s(]+((?:['"][^'"]*['"]\s*,?\s*)+)`)
+
+	// quotedRegexp matches a quoted string.
+	quotedRegexp = regexp.MustCompile(`['"]([^'"]*)['"]`)
+
+	// lockRubyVersionRegexp matches the RUBY VERSION section of a lock file, e.g. `ruby 2.7.1p83`.
+	lockRubyVersionRegexp = regexp.MustCompile(`(?m)^RUBY VERSION\s*\n\s+ruby (\d+(?:\.\d+)*)`)
+
+	// lockBundlerVersionRegexp matches the BUNDLED WITH section of a lock file.
+	lockBundlerVersionRegexp = regexp.MustCompile(`(?m)^BUNDLED WITH\s*\n\s+(\S+)`)
+)
+
+// metadata represents metadata stored for a runtime layer.
+type metadata struct {
+	Version        string `toml:"version"`
+	BundlerVersion string `toml:"bundler_version"`
+}
+
+func main() {
+	gcp.Main(detectFn, buildFn)
+}
+
+func detectFn(ctx *gcp.Context) error {
+	runtime.CheckOverride(ctx, "ruby")
+
+	// Only top-level files are considered: Ruby sources are common in other projects, e.g. in node_modules.
+	if ctx.FileExists("Gemfile") || ctx.FileExists("gems.rb") || ctx.FileExists("config.ru") {
+		return nil
+	}
+	if len(ctx.Glob("*.rb")) == 0 {
+		ctx.OptOut("No Gemfile, gems.rb, config.ru or *.rb files found at the application root.")
+	}
+	return nil
+}
+
+func buildFn(ctx *gcp.Context) error {
+	version, err := runtimeVersion(ctx)
+	if err != nil {
+		return err
+	}
+	bundlerVersion := lockedBundlerVersion(ctx)
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	var meta metadata
+	l := ctx.Layer(rubyLayer)
+	ctx.ReadMetadata(l, &meta)
+	if version == meta.Version && bundlerVersion == meta.BundlerVersion {
+		ctx.CacheHit(rubyLayer)
+		ctx.Logf("Runtime cache hit, skipping installation.")
+		return nil
+	}
+	ctx.CacheMiss(rubyLayer)
+
+	if version != meta.Version {
+		if err := runtime.InstallVersion(ctx, l, version, func(dir string) error {
+			archiveURL := fmt.Sprintf(rubyURL, version)
+			if code := ctx.HTTPStatus(archiveURL); code != http.StatusOK {
+				return gcp.UserErrorf("Runtime version %s does not exist at %s (status %d). You can specify the version with %s.", version, archiveURL, code, env.RuntimeVersion)
+			}
+
+			ctx.Logf("Installing Ruby v%s", version)
+			command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s", archiveURL, dir)
+			ctx.Exec([]string{"bash", "-c", command})
+			return nil
+		}); err != nil {
+			return err
+		}
+		meta.Version = version
+	}
+
+	// Install the Bundler version the bundle was locked with, as Bundler refuses to use an older major version.
+	if bundlerVersion != "" {
+		ctx.Logf("Installing Bundler v%s", bundlerVersion)
+		gem := filepath.Join(l.Root, "bin", "gem")
+		ctx.Exec([]string{gem, "install", "bundler", "--version", bundlerVersion, "--no-document"})
+	}
+	meta.BundlerVersion = bundlerVersion
+
+	ctx.WriteMetadata(l, meta, layers.Build, layers.Cache, layers.Launch)
+
+	ctx.AddBuildpackPlan(buildpackplan.Plan{
+		Name:    rubyLayer,
+		Version: version,
+	})
+	return nil
+}
+
+// runtimeVersion returns the version of Ruby to install, from the first source that specifies one, or the latest
+// release. The sources are, in order of precedence, the env var, .ruby-version, the `ruby` directive of the Gemfile
+// and the RUBY VERSION of the lock file.
+func runtimeVersion(ctx *gcp.Context) (string, error) {
+	constraints, err := versionConstraints(ctx)
+	if err != nil {
+		return "", err
+	}
+	return runtime.ResolveFirst(ctx, runtime.Ruby, constraints)
+}
+
+// versionConstraints returns all Ruby version constraints specified for the application, in order of precedence.
+func versionConstraints(ctx *gcp.Context) ([]runtime.Constraint, error) {
+	var constraints []runtime.Constraint
+	if c, ok := runtime.EnvConstraint(); ok {
+		constraints = append(constraints, c)
+	}
+
+	if path := filepath.Join(ctx.ApplicationRoot(), versionFile); ctx.FileExists(path) {
+		// .ruby-version holds a version such as `2.7.2`, optionally prefixed by the engine, e.g. `ruby-2.7.2`.
+		v := strings.TrimPrefix(strings.TrimSpace(string(ctx.ReadFile(path))), "ruby-")
+		if v == "" {
+			return nil, gcp.UserErrorf("%s exists but does not specify a version", versionFile)
+		}
+		constraints = append(constraints, runtime.Constraint{Value: v, Source: versionFile})
+	}
+
+	var manifestConstraint, lockConstraint *runtime.Constraint
+	for _, g := range gemfiles {
+		if path := filepath.Join(ctx.ApplicationRoot(), g.manifest); manifestConstraint == nil && ctx.FileExists(path) {
+			if v := gemfileRubyVersion(ctx.ReadFile(path)); v != "" {
+				manifestConstraint = &runtime.Constraint{Value: v, Source: g.manifest}
+			}
+		}
+		if path := filepath.Join(ctx.ApplicationRoot(), g.lock); lockConstraint == nil && ctx.FileExists(path) {
+			if m := lockRubyVersionRegexp.FindSubmatch(ctx.ReadFile(path)); m != nil {
+				lockConstraint = &runtime.Constraint{Value: string(m[1]), Source: g.lock}
+			}
+		}
+	}
+	for _, c := range []*runtime.Constraint{manifestConstraint, lockConstraint} {
+		if c != nil {
+			constraints = append(constraints, *c)
+		}
+	}
+	return constraints, nil
+}
+
+// gemfileRubyVersion returns the `ruby` directive of a Gemfile as a semver range, e.g. `>=2.7.0 2.7.x` for
+// `ruby '~> 2.7.0'`.
+func gemfileRubyVersion(b []byte) string {
+	m := rubyDirectiveRegexp.FindSubmatch(b)
+	if m == nil {
+		return ""
+	}
+	var requirements []string
+	for _, q := range quotedRegexp.FindAllSubmatch(m[1], -1) {
+		requirements = append(requirements, string(q[1]))
+	}
+	return gemRange(requirements)
+}
+
+// gemRange converts RubyGems requirements, e.g. `>= 2.6` and `< 3.0`, to a semver range.
+func gemRange(requirements []string) string {
+	var comparators []string
+	for _, r := range requirements {
+		r = strings.TrimSpace(r)
+		switch {
+		case strings.HasPrefix(r, "~>"):
+			// A pessimistic constraint, e.g. `~> 2.7.1` is `>= 2.7.1` and `< 2.8`.
+			v := strings.TrimSpace(strings.TrimPrefix(r, "~>"))
+			parts := strings.Split(v, ".")
+			comparators = append(comparators, ">="+v)
+			if len(parts) > 1 {
+				comparators = append(comparators, strings.Join(parts[:len(parts)-1], ".")+".x")
+			}
+		case strings.HasPrefix(r, "="):
+			comparators = append(comparators, strings.TrimSpace(strings.TrimPrefix(r, "=")))
+		case r != "":
+			comparators = append(comparators, strings.Replace(r, " ", "", -1))
+		}
+	}
+	return strings.Join(comparators, " ")
+}
+
+// lockedBundlerVersion returns the Bundler version in the BUNDLED WITH section of the lock file, if any.
+func lockedBundlerVersion(ctx *gcp.Context) string {
+	for _, g := range gemfiles {
+		path := filepath.Join(ctx.ApplicationRoot(), g.lock)
+		if !ctx.FileExists(path) {
+			continue
+		}
+		if m := lockBundlerVersionRegexp.FindSubmatch(ctx.ReadFile(path)); m != nil {
+			return string(m[1])
+		}
+	}
+	return ""
+}
@@ -0,0 +1,204 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/runtime"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+const lockFile = `GEM
+  remote: https://rubygems.org/
+  specs:
+    rack (2.2.2)
+
+PLATFORMS
+  ruby
+
+DEPENDENCIES
+  rack
+
+RUBY VERSION
+   ruby 2.7.1p83
+
+BUNDLED WITH
+   2.1.4
+`
+
+func TestDetect(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  int
+	}{
+		{
+			name: "Gemfile",
+			files: map[string]string{
+				"Gemfile": "",
+			},
+			want: 0,
+		},
+		{
+			name: "gems.rb",
+			files: map[string]string{
+				"gems.rb": "",
+			},
+			want: 0,
+		},
+		{
+			name: "rb files",
+			files: map[string]string{
+				"app.rb": "",
+			},
+			want: 0,
+		},
+		{
+			name: "config.ru",
+			files: map[string]string{
+				"config.ru": "",
+			},
+			want: 0,
+		},
+		{
+			name:  "no ruby files",
+			files: map[string]string{},
+			want:  100,
+		},
+		{
+			name: "nested rb files",
+			files: map[string]string{
+				"package.json":                 "",
+				"node_modules/dep/lib/tool.rb": "",
+			},
+			want: 100,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			gcp.TestDetect(t, detectFn, tc.name, tc.files, []string{}, tc.want)
+		})
+	}
+}
+
+func TestVersionConstraints(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []runtime.Constraint
+	}{
+		{
+			name:  "none",
+			files: map[string]string{"app.rb": ""},
+		},
+		{
+			name:  ".ruby-version with engine",
+			files: map[string]string{".ruby-version": "ruby-2.7.2\n"},
+			want:  []runtime.Constraint{{Value: "2.7.2", Source: ".ruby-version"}},
+		},
+		{
+			name:  "Gemfile",
+			files: map[string]string{"Gemfile": "source \"https://rubygems.org\"\nruby '~> 2.7.0'\ngem 'rack'\n"},
+			want:  []runtime.Constraint{{Value: ">=2.7.0 2.7.x", Source: "Gemfile"}},
+		},
+		{
+			name:  "gems.rb with several requirements",
+			files: map[string]string{"gems.rb": "ruby \">= 2.6\", \"< 3.0\", engine: \"ruby\"\n"},
+			want:  []runtime.Constraint{{Value: ">=2.6 <3.0", Source: "gems.rb"}},
+		},
+		{
+			name:  "Gemfile without ruby directive",
+			files: map[string]string{"Gemfile": "gem 'ruby-progressbar'\n", "Gemfile.lock": lockFile},
+			want:  []runtime.Constraint{{Value: "2.7.1", Source: "Gemfile.lock"}},
+		},
+		{
+			name: "all sources in order",
+			files: map[string]string{
+				".ruby-version": "2.7.2",
+				"Gemfile":       "ruby '2.7.1'\n",
+				"Gemfile.lock":  lockFile,
+			},
+			want: []runtime.Constraint{
+				{Value: "2.7.2", Source: ".ruby-version"},
+				{Value: "2.7.1", Source: "Gemfile"},
+				{Value: "2.7.1", Source: "Gemfile.lock"},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeApp(t, tc.files)
+			defer os.RemoveAll(dir)
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := versionConstraints(ctx)
+			if err != nil {
+				t.Fatalf("versionConstraints() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("versionConstraints() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestGemRange(t *testing.T) {
+	testCases := []struct {
+		requirements []string
+		want         string
+	}{
+		{requirements: []string{"2.7.1"}, want: "2.7.1"},
+		{requirements: []string{"= 2.7.1"}, want: "2.7.1"},
+		{requirements: []string{"~> 2.7"}, want: ">=2.7 2.x"},
+		{requirements: []string{"~> 2.7.1"}, want: ">=2.7.1 2.7.x"},
+		{requirements: []string{">= 2.6", "< 3.0"}, want: ">=2.6 <3.0"},
+		{requirements: []string{">= 2.6", "!= 2.7.0"}, want: ">=2.6 !=2.7.0"},
+	}
+	for _, tc := range testCases {
+		if got := gemRange(tc.requirements); got != tc.want {
+			t.Errorf("gemRange(%q) = %q, want %q", tc.requirements, got, tc.want)
+		}
+	}
+}
+
+func TestLockedBundlerVersion(t *testing.T) {
+	dir := writeApp(t, map[string]string{"Gemfile.lock": lockFile})
+	defer os.RemoveAll(dir)
+	ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+	if got, want := lockedBundlerVersion(ctx), "2.1.4"; got != want {
+		t.Errorf("lockedBundlerVersion() = %q, want %q", got, want)
+	}
+}
+
+func writeApp(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "app-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	for f, c := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	return dir
+}
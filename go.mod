@@ -4,7 +4,7 @@ module github.com/GoogleCloudPlatform/buildpacks
 go 1.14
 
 require (
+	github.com/BurntSushi/toml v0.3.1
 	github.com/blang/semver v3.5.2-0.20180723201105-3c1074078d32+incompatible
-	github.com/BurntSushi/toml v0.3.1-0.20170626110600-a368813c5e64
 	github.com/buildpack/libbuildpack v1.25.11
 )
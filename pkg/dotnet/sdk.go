@@ -0,0 +1,203 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/blang/semver"
+)
+
+var (
+	// targetFrameworkRegexp matches .NET Core and .NET 5+ target framework monikers, e.g. `netcoreapp3.1` or `net5.0`.
+	// .NET Framework (e.g. `net48`) and .NET Standard monikers do not match.
+	targetFrameworkRegexp = regexp.MustCompile(`^net(?:coreapp)?(\d+)\.(\d+)(?:-.*)?$`)
+)
+
+// GlobalJSON represents the contents of a global.json file, see
+// https://docs.microsoft.com/en-us/dotnet/core/tools/global-json.
+type GlobalJSON struct {
+	Sdk struct {
+		Version         string `json:"version"`
+		RollForward     string `json:"rollForward"`
+		AllowPrerelease *bool  `json:"allowPrerelease"`
+	} `json:"sdk"`
+}
+
+// TargetFrameworks returns all target framework monikers of the project, e.g. `netcoreapp3.1`.
+func (p Project) TargetFrameworks() []string {
+	var tfms []string
+	for _, pg := range p.PropertyGroups {
+		if tfm := strings.TrimSpace(pg.TargetFramework); tfm != "" {
+			tfms = append(tfms, tfm)
+		}
+		for _, tfm := range strings.Split(pg.TargetFrameworks, ";") {
+			if tfm = strings.TrimSpace(tfm); tfm != "" {
+				tfms = append(tfms, tfm)
+			}
+		}
+	}
+	return tfms
+}
+
+// TargetFrameworkVersion returns the .NET version targeted by a target framework moniker, e.g. 5.0 for `net5.0`.
+// It returns false for monikers that do not target .NET Core or .NET 5+.
+func TargetFrameworkVersion(tfm string) (semver.Version, bool) {
+	m := targetFrameworkRegexp.FindStringSubmatch(strings.ToLower(tfm))
+	if m == nil {
+		return semver.Version{}, false
+	}
+	major, _ := strconv.ParseUint(m[1], 10, 64)
+	minor, _ := strconv.ParseUint(m[2], 10, 64)
+	return semver.Version{Major: major, Minor: minor}, true
+}
+
+// sdkVersion is a .NET SDK version. The patch component of an SDK version holds the feature band and the patch level,
+// e.g. 3.1.402 is in feature band 400 at patch level 2.
+type sdkVersion struct {
+	semver.Version
+	raw  string
+	band uint64
+}
+
+func parseSDKVersion(v string) (sdkVersion, error) {
+	sv, err := semver.Parse(strings.TrimSpace(v))
+	if err != nil {
+		return sdkVersion{}, fmt.Errorf("invalid .NET SDK version %q: %v", v, err)
+	}
+	return sdkVersion{Version: sv, raw: v, band: sv.Patch / 100}, nil
+}
+
+// featureKey identifies the feature band of an SDK version.
+func (v sdkVersion) featureKey() [3]uint64 {
+	return [3]uint64{v.Major, v.Minor, v.band}
+}
+
+func lessKey(a, b [3]uint64) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+	return false
+}
+
+// SelectSDK returns the SDK among available that global.json's version selects under its rollForward policy, see
+// https://docs.microsoft.com/en-us/dotnet/core/tools/global-json#rollforward. The policy defaults to `latestPatch`.
+// Pre-release SDKs are only selected if allowPrerelease is true or if requested exactly.
+func SelectSDK(available []string, version, rollForward string, allowPrerelease bool) (string, error) {
+	req, err := parseSDKVersion(version)
+	if err != nil {
+		return "", err
+	}
+
+	var candidates []sdkVersion
+	for _, a := range available {
+		v, err := parseSDKVersion(a)
+		if err != nil {
+			continue
+		}
+		if v.raw == req.raw {
+			if strings.EqualFold(rollForward, "disable") || strings.EqualFold(rollForward, "patch") {
+				return v.raw, nil
+			}
+		}
+		if len(v.Pre) > 0 && !allowPrerelease && !v.EQ(req.Version) {
+			continue
+		}
+		if v.GTE(req.Version) {
+			candidates = append(candidates, v)
+		}
+	}
+
+	inBand := func(v sdkVersion) bool { return v.featureKey() == req.featureKey() }
+	higherBand := func(v sdkVersion) bool { return v.Major == req.Major && v.Minor == req.Minor && v.band > req.band }
+	higherMinor := func(v sdkVersion) bool { return v.Major == req.Major && v.Minor > req.Minor }
+	higherMajor := func(v sdkVersion) bool { return v.Major > req.Major }
+	sameMinor := func(v sdkVersion) bool { return v.Major == req.Major && v.Minor == req.Minor }
+	sameMajor := func(v sdkVersion) bool { return v.Major == req.Major }
+	all := func(sdkVersion) bool { return true }
+
+	var found string
+	switch strings.ToLower(rollForward) {
+	case "disable":
+	case "patch":
+		found = nearest(candidates, inBand)
+	case "feature":
+		found = nearest(candidates, inBand, higherBand)
+	case "minor":
+		found = nearest(candidates, inBand, higherBand, higherMinor)
+	case "major":
+		found = nearest(candidates, inBand, higherBand, higherMinor, higherMajor)
+	case "", "latestpatch":
+		found = latest(candidates, inBand)
+	case "latestfeature":
+		found = latest(candidates, sameMinor)
+	case "latestminor":
+		found = latest(candidates, sameMajor)
+	case "latestmajor":
+		found = latest(candidates, all)
+	default:
+		return "", fmt.Errorf("unsupported rollForward policy %q", rollForward)
+	}
+	if found == "" {
+		policy := rollForward
+		if policy == "" {
+			policy = "latestPatch"
+		}
+		return "", fmt.Errorf("no .NET SDK matching version %s with rollForward policy %s", version, policy)
+	}
+	return found, nil
+}
+
+// nearest returns the latest patch of the lowest feature band among candidates accepted by the first filter that
+// accepts any.
+func nearest(candidates []sdkVersion, filters ...func(sdkVersion) bool) string {
+	for _, f := range filters {
+		var band []sdkVersion
+		for _, c := range candidates {
+			if !f(c) {
+				continue
+			}
+			switch {
+			case len(band) == 0 || lessKey(c.featureKey(), band[0].featureKey()):
+				band = []sdkVersion{c}
+			case c.featureKey() == band[0].featureKey():
+				band = append(band, c)
+			}
+		}
+		if len(band) > 0 {
+			return latest(band, f)
+		}
+	}
+	return ""
+}
+
+// latest returns the highest version among candidates accepted by filter.
+func latest(candidates []sdkVersion, filter func(sdkVersion) bool) string {
+	var best *sdkVersion
+	for i, c := range candidates {
+		if filter(c) && (best == nil || c.GT(best.Version)) {
+			best = &candidates[i]
+		}
+	}
+	if best == nil {
+		return ""
+	}
+	return best.raw
+}
@@ -0,0 +1,122 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dotnet
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSelectSDK(t *testing.T) {
+	available := []string{
+		"2.1.811",
+		"3.1.101",
+		"3.1.102",
+		"3.1.201",
+		"3.1.202",
+		"3.1.402",
+		"5.0.100-rc.2.20479.15",
+		"5.0.100",
+		"5.0.101",
+	}
+	testCases := []struct {
+		version         string
+		rollForward     string
+		allowPrerelease bool
+		want            string
+		wantErr         bool
+	}{
+		{version: "3.1.101", rollForward: "", want: "3.1.102"},
+		{version: "3.1.101", rollForward: "latestPatch", want: "3.1.102"},
+		{version: "3.1.101", rollForward: "patch", want: "3.1.101"},
+		{version: "3.1.100", rollForward: "patch", want: "3.1.102"},
+		{version: "3.1.300", rollForward: "patch", wantErr: true},
+		{version: "3.1.101", rollForward: "disable", want: "3.1.101"},
+		{version: "3.1.100", rollForward: "disable", wantErr: true},
+		{version: "3.1.103", rollForward: "feature", want: "3.1.202"},
+		{version: "3.1.403", rollForward: "feature", wantErr: true},
+		{version: "3.1.103", rollForward: "latestFeature", want: "3.1.402"},
+		{version: "3.0.100", rollForward: "minor", want: "3.1.102"},
+		{version: "3.0.100", rollForward: "latestMinor", want: "3.1.402"},
+		{version: "3.1.403", rollForward: "minor", wantErr: true},
+		{version: "3.1.403", rollForward: "major", want: "5.0.101"},
+		{version: "2.1.500", rollForward: "major", want: "2.1.811"},
+		{version: "2.1.900", rollForward: "major", want: "3.1.102"},
+		{version: "2.1.500", rollForward: "latestMajor", want: "5.0.101"},
+		{version: "5.0.100-rc.1.20452.10", rollForward: "latestPatch", want: "5.0.101"},
+		{version: "5.0.100-rc.1.20452.10", rollForward: "patch", allowPrerelease: true, want: "5.0.101"},
+		{version: "5.0.100-rc.2.20479.15", rollForward: "disable", want: "5.0.100-rc.2.20479.15"},
+		{version: "3.1.101", rollForward: "sideways", wantErr: true},
+		{version: "3.1", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version+"/"+tc.rollForward, func(t *testing.T) {
+			got, err := SelectSDK(available, tc.version, tc.rollForward, tc.allowPrerelease)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("SelectSDK(%q, %q) = %q, want error", tc.version, tc.rollForward, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SelectSDK(%q, %q) got error: %v", tc.version, tc.rollForward, err)
+			}
+			if got != tc.want {
+				t.Errorf("SelectSDK(%q, %q) = %q, want %q", tc.version, tc.rollForward, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTargetFrameworkVersion(t *testing.T) {
+	testCases := []struct {
+		tfm    string
+		want   string
+		wantOK bool
+	}{
+		{tfm: "netcoreapp3.1", want: "3.1.0", wantOK: true},
+		{tfm: "netcoreapp2.1", want: "2.1.0", wantOK: true},
+		{tfm: "net5.0", want: "5.0.0", wantOK: true},
+		{tfm: "net5.0-windows", want: "5.0.0", wantOK: true},
+		{tfm: "netstandard2.0"},
+		{tfm: "net48"},
+	}
+	for _, tc := range testCases {
+		got, ok := TargetFrameworkVersion(tc.tfm)
+		if ok != tc.wantOK || (ok && got.String() != tc.want) {
+			t.Errorf("TargetFrameworkVersion(%q) = %v, %t, want %s, %t", tc.tfm, got, ok, tc.want, tc.wantOK)
+		}
+	}
+}
+
+func TestTargetFrameworks(t *testing.T) {
+	proj := `<Project Sdk="Microsoft.NET.Sdk">
+  <PropertyGroup>
+    <TargetFramework>netcoreapp3.1</TargetFramework>
+  </PropertyGroup>
+  <PropertyGroup>
+    <TargetFrameworks>netstandard2.0; net5.0</TargetFrameworks>
+  </PropertyGroup>
+</Project>`
+	p, err := readProjectFile([]byte(proj), "app.csproj")
+	if err != nil {
+		t.Fatalf("readProjectFile() got error: %v", err)
+	}
+
+	want := []string{"netcoreapp3.1", "netstandard2.0", "net5.0"}
+	if got := p.TargetFrameworks(); !reflect.DeepEqual(got, want) {
+		t.Errorf("TargetFrameworks() = %v, want %v", got, want)
+	}
+}
@@ -19,6 +19,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"time"
 )
 
 const (
@@ -89,6 +90,37 @@ const (
 	// GoLDFlags is an env var used to pass through linker flags to the Go linker.
 	// Example: `-s -w` is sometimes used to strip and reduce binary size.
 	GoLDFlags = "GOOGLE_GOLDFLAGS"
+
+	// JavaJlink is an env var used to replace the JRE launch layer of Java applications with a minimal runtime created
+	// by jlink from the modules the executable jar depends on.
+	// Example: `true`, `True`, `1` will enable jlink.
+	JavaJlink = "GOOGLE_JAVA_JLINK"
+
+	// NodeRunScripts is an env var used to specify a comma-separated list of package.json scripts run during the build
+	// of Node.js applications, with devDependencies installed. Scripts that are not defined are skipped.
+	// Example: `lint,build` runs the lint script then the build script. Defaults to `gcp-build`, or `build` if absent.
+	NodeRunScripts = "GOOGLE_NODE_RUN_SCRIPTS"
+	// NPMRCFile is an env var used to specify the path of an .npmrc file, typically a mounted secret, with the
+	// credentials of private registries. It is only used while installing dependencies and never stored in an image.
+	// Example: `/secrets/npmrc`.
+	NPMRCFile = "GOOGLE_NPMRC_FILE"
+
+	// PHPWebServer is an env var used to select the web server that serves PHP applications without an entrypoint.
+	// Example: `nginx` (the default) serves the application with nginx and php-fpm, `builtin` with PHP's built-in web server.
+	PHPWebServer = "GOOGLE_PHP_WEBSERVER"
+	// PHPDocumentRoot is an env var used to specify the directory, relative to the application root, served by the web server.
+	// Example: `web` serves files from the web directory. Defaults to `public` if that directory exists and `.` otherwise.
+	PHPDocumentRoot = "GOOGLE_PHP_DOCUMENT_ROOT"
+
+	// MaxLaunchSize is an env var used to limit the total size of the launch layers of the image, across buildpacks.
+	// The buildpack after which the launch layers exceed the limit fails with RESOURCE_EXHAUSTED.
+	// Example: `500M`; the value is in bytes, optionally suffixed with K, M or G (powers of 1024).
+	MaxLaunchSize = "GOOGLE_MAX_LAUNCH_SIZE"
+
+	// SourceDateEpoch is an env var used to enable reproducible builds (https://reproducible-builds.org/specs/source-date-epoch/).
+	// When set, timestamps of files in layers are normalized to its value, and compilers and package managers are run with deterministic settings.
+	// Example: `1589480000`, typically the commit time of the source being built.
+	SourceDateEpoch = "SOURCE_DATE_EPOCH"
 )
 
 // IsDebugMode returns true if the buildpack debug mode is enabled.
@@ -103,3 +135,19 @@ func IsDebugMode() (bool, error) {
 	}
 	return parsed, nil
 }
+
+// SourceDate returns the time specified by SOURCE_DATE_EPOCH, and whether it is set.
+func SourceDate() (time.Time, bool, error) {
+	val, found := os.LookupEnv(SourceDateEpoch)
+	if !found {
+		return time.Time{}, false, nil
+	}
+	secs, err := strconv.ParseInt(val, 10, 64)
+	if err != nil {
+		return time.Time{}, false, fmt.Errorf("parsing %s: %v", SourceDateEpoch, err)
+	}
+	if secs < 0 {
+		return time.Time{}, false, fmt.Errorf("parsing %s: %d is negative", SourceDateEpoch, secs)
+	}
+	return time.Unix(secs, 0).UTC(), true, nil
+}
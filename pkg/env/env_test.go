@@ -17,6 +17,7 @@ package env
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestIsDebugMode(t *testing.T) {
@@ -90,3 +91,76 @@ func TestIsDebugMode(t *testing.T) {
 		})
 	}
 }
+
+func TestSourceDate(t *testing.T) {
+	testCases := []struct {
+		name      string
+		notSet    bool
+		value     string
+		wantErr   bool
+		wantFound bool
+		want      time.Time
+	}{
+		{
+			name:   "not set",
+			notSet: true,
+		},
+		{
+			name:    "set to empty",
+			wantErr: true,
+		},
+		{
+			name:    "set to bad value",
+			value:   "yesterday",
+			wantErr: true,
+		},
+		{
+			name:    "set to negative value",
+			value:   "-1",
+			wantErr: true,
+		},
+		{
+			name:      "set to zero",
+			value:     "0",
+			wantFound: true,
+			want:      time.Unix(0, 0),
+		},
+		{
+			name:      "set to timestamp",
+			value:     "1589480000",
+			wantFound: true,
+			want:      time.Unix(1589480000, 0),
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.notSet {
+				if err := os.Unsetenv(SourceDateEpoch); err != nil {
+					t.Fatalf("Failed to unset env: %v", err)
+				}
+			} else {
+				if err := os.Setenv(SourceDateEpoch, tc.value); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func() {
+					if err := os.Unsetenv(SourceDateEpoch); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}()
+			}
+
+			got, found, err := SourceDate()
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("got err=%t, want err=%t: %v", err != nil, tc.wantErr, err)
+			}
+			if found != tc.wantFound {
+				t.Errorf("SourceDate() found=%t, want=%t", found, tc.wantFound)
+			}
+			if tc.wantFound && !got.Equal(tc.want) {
+				t.Errorf("SourceDate()=%v, want=%v", got, tc.want)
+			}
+		})
+	}
+}
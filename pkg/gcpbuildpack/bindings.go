@@ -0,0 +1,80 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// serviceBindingRootEnv is the env var set by the platform to the directory containing service bindings.
+const serviceBindingRootEnv = "SERVICE_BINDING_ROOT"
+
+// Binding is a service binding provided by the platform: a directory with a type and secret entries, one file each.
+type Binding struct {
+	Name string
+	Type string
+	Path string
+}
+
+// Entry returns the contents of the named entry of the binding, or nil if it does not exist.
+func (b Binding) Entry(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(filepath.Join(b.Path, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading entry %s of binding %s: %w", name, b.Name, err)
+	}
+	return data, nil
+}
+
+// Bindings returns the service bindings of the given type, case-insensitively, sorted by name. Bindings are read from
+// SERVICE_BINDING_ROOT if set, otherwise from the bindings directory of the platform.
+func (ctx *Context) Bindings(bindingType string) ([]Binding, error) {
+	root := os.Getenv(serviceBindingRootEnv)
+	if root == "" && ctx.b != nil {
+		root = filepath.Join(ctx.b.Platform.Root, "bindings")
+	}
+	if root == "" {
+		return nil, nil
+	}
+	fis, err := ioutil.ReadDir(root)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading bindings in %s: %w", root, err)
+	}
+	var bindings []Binding
+	for _, fi := range fis {
+		if !fi.IsDir() {
+			continue
+		}
+		b := Binding{Name: fi.Name(), Path: filepath.Join(root, fi.Name())}
+		t, err := b.Entry("type")
+		if err != nil {
+			return nil, err
+		}
+		b.Type = strings.TrimSpace(string(t))
+		if strings.EqualFold(b.Type, bindingType) {
+			bindings = append(bindings, b)
+		}
+	}
+	return bindings, nil
+}
@@ -0,0 +1,78 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestBindings(t *testing.T) {
+	root, err := ioutil.TempDir("", "bindings-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+	files := map[string]string{
+		"registry/type":     "npmrc\n",
+		"registry/.npmrc":   "//registry.example.com/:_authToken=abc\n",
+		"another/type":      "NPMRC",
+		"database/type":     "postgresql",
+		"database/password": "secret",
+		"untyped/.npmrc":    "",
+	}
+	for f, c := range files {
+		if err := os.MkdirAll(filepath.Join(root, filepath.Dir(f)), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", f, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(root, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	defer os.Unsetenv(serviceBindingRootEnv)
+	if err := os.Setenv(serviceBindingRootEnv, root); err != nil {
+		t.Fatalf("setting %s: %v", serviceBindingRootEnv, err)
+	}
+
+	ctx := NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+	got, err := ctx.Bindings("npmrc")
+	if err != nil {
+		t.Fatalf("Bindings() got error: %v", err)
+	}
+	want := []Binding{
+		{Name: "another", Type: "NPMRC", Path: filepath.Join(root, "another")},
+		{Name: "registry", Type: "npmrc", Path: filepath.Join(root, "registry")},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Bindings() = %v, want %v", got, want)
+	}
+
+	npmrc, err := got[1].Entry(".npmrc")
+	if err != nil {
+		t.Fatalf("Entry() got error: %v", err)
+	}
+	if string(npmrc) != files["registry/.npmrc"] {
+		t.Errorf("Entry(.npmrc) = %q, want %q", npmrc, files["registry/.npmrc"])
+	}
+	missing, err := got[0].Entry(".npmrc")
+	if err != nil || missing != nil {
+		t.Errorf("Entry(.npmrc) = %q, %v, want nil, nil", missing, err)
+	}
+}
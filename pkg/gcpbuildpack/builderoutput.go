@@ -60,6 +60,15 @@ type builderStat struct {
 	BuildpackVersion string `json:"buildpackVersion"`
 	DurationMs       int64  `json:"totalDurationMs"`
 	UserDurationMs   int64  `json:"userDurationMs"`
+
+	// Resource usage aggregated over all commands run by the buildpack.
+	UserCPUMs      int64 `json:"userCpuMs,omitempty"`
+	SystemCPUMs    int64 `json:"systemCpuMs,omitempty"`
+	MaxRSSKB       int64 `json:"maxRssKb,omitempty"`
+	BlockInputOps  int64 `json:"blockInputOps,omitempty"`
+	BlockOutputOps int64 `json:"blockOutputOps,omitempty"`
+
+	Layers []layerSize `json:"layers,omitempty"`
 }
 
 func (e *Error) Error() string {
@@ -225,6 +234,12 @@ func (ctx *Context) saveSuccessOutput(duration time.Duration) {
 		BuildpackVersion: ctx.BuildpackVersion(),
 		DurationMs:       duration.Milliseconds(),
 		UserDurationMs:   ctx.stats.user.Milliseconds(),
+		UserCPUMs:        ctx.stats.usage.userCPU.Milliseconds(),
+		SystemCPUMs:      ctx.stats.usage.systemCPU.Milliseconds(),
+		MaxRSSKB:         ctx.stats.usage.maxRSSKB,
+		BlockInputOps:    ctx.stats.usage.inBlocks,
+		BlockOutputOps:   ctx.stats.usage.outBlocks,
+		Layers:           ctx.stats.layers,
 	})
 
 	content, err := json.Marshal(&bo)
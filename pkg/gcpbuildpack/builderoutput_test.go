@@ -269,6 +269,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 	testCases := []struct {
 		name    string
 		initial []builderStat
+		usage   resourceUsage
 		want    []builderStat
 	}{
 		{
@@ -277,6 +278,14 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 				{BuildpackID: buildpackID, BuildpackVersion: buildpackVersion, DurationMs: dur.Milliseconds(), UserDurationMs: userDur.Milliseconds()},
 			},
 		},
+		{
+			name:  "resource usage",
+			usage: resourceUsage{userCPU: 4 * time.Second, systemCPU: time.Second, maxRSSKB: 2048, inBlocks: 10, outBlocks: 20},
+			want: []builderStat{
+				{BuildpackID: buildpackID, BuildpackVersion: buildpackVersion, DurationMs: dur.Milliseconds(), UserDurationMs: userDur.Milliseconds(),
+					UserCPUMs: 4000, SystemCPUMs: 1000, MaxRSSKB: 2048, BlockInputOps: 10, BlockOutputOps: 20},
+			},
+		},
 		{
 			name: "existing file",
 			initial: []builderStat{
@@ -318,6 +327,7 @@ func TestSaveBuilderSuccessOutput(t *testing.T) {
 			}
 			ctx := NewContext(buildpack.Info{ID: buildpackID, Version: buildpackVersion, Name: "name"})
 			ctx.stats.user = userDur
+			ctx.stats.usage = tc.usage
 
 			ctx.saveSuccessOutput(dur)
 
@@ -0,0 +1,134 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/buildpack/libbuildpack/buildplan"
+)
+
+// Task is a unit of work run by RunConcurrently.
+type Task struct {
+	// Name identifies the task in logs and spans.
+	Name string
+	// Fn performs the work using the task's own Context, whose output is buffered until all tasks complete.
+	Fn func(*Context) error
+}
+
+// RunConcurrently runs the given tasks concurrently and waits for all of them to complete.
+// The output of each task is emitted in task order once all tasks complete, so that output of different tasks
+// does not interleave. Each task is recorded in its own span. The first error in task order is returned.
+// Helpers that exit the process on failure, e.g. ExecUser, instead fail the task within a task's Context.
+// Spans, user durations, resource usage, secrets, build plan entries and processes of the tasks are merged back into
+// ctx in task order.
+func (ctx *Context) RunConcurrently(tasks ...Task) error {
+	children := make([]*Context, len(tasks))
+	errs := make([]error, len(tasks))
+
+	var wg sync.WaitGroup
+	for i, t := range tasks {
+		children[i] = ctx.taskContext()
+		wg.Add(1)
+		go func(c *Context, t Task, i int) {
+			defer wg.Done()
+			errs[i] = c.runTask(t)
+		}(children[i], t, i)
+	}
+	wg.Wait()
+
+	out := ctx.stderr()
+	var first error
+	for i, c := range children {
+		out.Write(c.taskOutput.Bytes())
+		ctx.stats.spans = append(ctx.stats.spans, c.stats.spans...)
+		ctx.stats.user += c.stats.user
+		ctx.stats.usage.add(c.stats.usage)
+		ctx.secrets = append(ctx.secrets, c.secrets[len(ctx.secrets):]...)
+		ctx.buildPlan.Provides = append(ctx.buildPlan.Provides, c.buildPlan.Provides...)
+		ctx.buildPlan.Requires = append(ctx.buildPlan.Requires, c.buildPlan.Requires...)
+		ctx.buildpackPlans = append(ctx.buildpackPlans, c.buildpackPlans...)
+		for _, p := range c.processes {
+			ctx.addProcess(p)
+		}
+		if errs[i] != nil && first == nil {
+			first = fmt.Errorf("running task %q: %w", tasks[i].Name, errs[i])
+			// Only the command that failed the returned task is relevant to diagnostics.
+			if c.failedExec != nil {
+				ctx.failedExec = c.failedExec
+			}
+		}
+	}
+	return first
+}
+
+// taskContext returns a copy of ctx for a concurrent task, with its own stats, buffered output, build plan and
+// processes. The secrets of ctx are copied so that secrets added by the task are redacted in its output.
+func (ctx *Context) taskContext() *Context {
+	c := *ctx
+	c.stats = stats{}
+	c.taskOutput = &lockingBuffer{}
+	c.taskLogger = log.New(c.taskOutput, "", 0)
+	c.task = true
+	c.secrets = append([]string{}, ctx.secrets...)
+	c.buildPlan = buildplan.Plan{}
+	c.buildpackPlans = nil
+	c.processes = nil
+	c.failedExec = nil
+	return &c
+}
+
+// taskExit is the panic value of Exit within a concurrent task.
+type taskExit struct {
+	exitCode int
+	err      *Error
+}
+
+func (ctx *Context) runTask(t Task) (err error) {
+	status := StatusInternal
+	defer func(start time.Time) {
+		ctx.Span(fmt.Sprintf("Task %s", t.Name), start, status)
+	}(time.Now())
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		te, ok := r.(taskExit)
+		if !ok {
+			panic(r)
+		}
+		if te.err == nil {
+			te.err = InternalErrorf("exited with code %d", te.exitCode)
+		}
+		status = te.err.Status
+		err = te.err
+	}()
+
+	ctx.Logf("--- %s ---", t.Name)
+	if err := t.Fn(ctx); err != nil {
+		var be *Error
+		if errors.As(err, &be) {
+			status = be.Status
+		}
+		return err
+	}
+	status = StatusOk
+	return nil
+}
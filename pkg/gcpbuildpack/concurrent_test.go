@@ -0,0 +1,168 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"errors"
+	"log"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/buildpack/libbuildpack/buildpackplan"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+func TestRunConcurrentlyDoesNotInterleaveOutput(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	// Capture the output of the parent context.
+	ctx.debug = true
+	ctx.taskOutput = &lockingBuffer{}
+	ctx.taskLogger = log.New(ctx.taskOutput, "", 0)
+
+	start := time.Now()
+	err := ctx.RunConcurrently(
+		Task{Name: "first", Fn: func(c *Context) error {
+			c.Exec([]string{"bash", "-c", "echo first-1; sleep .2; echo first-2"})
+			return nil
+		}},
+		Task{Name: "second", Fn: func(c *Context) error {
+			c.Exec([]string{"bash", "-c", "sleep .1; echo second-1"})
+			return nil
+		}},
+	)
+	if err != nil {
+		t.Fatalf("RunConcurrently() got error: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= 300*time.Millisecond {
+		t.Errorf("RunConcurrently() took %v, want < %v", elapsed, 300*time.Millisecond)
+	}
+
+	got := string(ctx.taskOutput.Bytes())
+	var order []int
+	for _, s := range []string{"--- first ---", "first-1", "first-2", "--- second ---", "second-1"} {
+		order = append(order, strings.Index(got, s))
+	}
+	for i := range order {
+		if order[i] < 0 || (i > 0 && order[i] < order[i-1]) {
+			t.Fatalf("RunConcurrently() output is missing or out of order, got:\n%s", got)
+		}
+	}
+}
+
+func TestRunConcurrentlyEmitsSpans(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	ctx.RunConcurrently(
+		Task{Name: "ok", Fn: func(c *Context) error {
+			c.Exec([]string{"true"})
+			return nil
+		}},
+		Task{Name: "failed", Fn: func(c *Context) error {
+			return UserErrorf("failed")
+		}},
+	)
+
+	got := map[string]Status{}
+	for _, s := range ctx.stats.spans {
+		got[s.name] = s.status
+	}
+	want := map[string]Status{
+		`Exec "true"`: StatusOk,
+		"Task ok":     StatusOk,
+		"Task failed": StatusUnknown,
+	}
+	for name, status := range want {
+		if s, ok := got[name]; !ok || s != status {
+			t.Errorf("span %q got status=%v (present=%t), want %v", name, s, ok, status)
+		}
+	}
+}
+
+func TestRunConcurrentlyReturnsFirstError(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	errFirst, errSecond := errors.New("first"), errors.New("second")
+
+	err := ctx.RunConcurrently(
+		Task{Name: "ok", Fn: func(c *Context) error { return nil }},
+		Task{Name: "first", Fn: func(c *Context) error {
+			time.Sleep(100 * time.Millisecond)
+			return errFirst
+		}},
+		Task{Name: "second", Fn: func(c *Context) error { return errSecond }},
+	)
+
+	if !errors.Is(err, errFirst) {
+		t.Errorf("RunConcurrently() got error %v, want %v", err, errFirst)
+	}
+}
+
+func TestRunConcurrentlyTaskExitReturnsError(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	ctx.taskOutput = &lockingBuffer{}
+	ctx.taskLogger = log.New(ctx.taskOutput, "", 0)
+
+	err := ctx.RunConcurrently(
+		Task{Name: "ok", Fn: func(c *Context) error {
+			c.Logf("sibling output")
+			return nil
+		}},
+		Task{Name: "failed", Fn: func(c *Context) error {
+			c.ExecUser([]string{"bash", "-c", "exit 3"})
+			return nil
+		}},
+	)
+
+	var be *Error
+	if !errors.As(err, &be) || be.Status != StatusUnknown {
+		t.Errorf("RunConcurrently() got error %v, want user error from ExecUser", err)
+	}
+	if got := string(ctx.taskOutput.Bytes()); !strings.Contains(got, "sibling output") {
+		t.Errorf("RunConcurrently() output = %q, want output of the sibling task", got)
+	}
+}
+
+func TestRunConcurrentlyMergesProcessesAndPlans(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	ctx.AddWebProcess([]string{"old"})
+
+	err := ctx.RunConcurrently(
+		Task{Name: "web", Fn: func(c *Context) error {
+			c.AddWebProcess([]string{"serve", "--port", "8080"})
+			return nil
+		}},
+		Task{Name: "plan", Fn: func(c *Context) error {
+			c.AddBuildpackPlan(buildpackplan.Plan{Name: "dep"})
+			return nil
+		}},
+	)
+	if err != nil {
+		t.Fatalf("RunConcurrently() got error: %v", err)
+	}
+
+	want := layers.Processes{{Type: "web", Command: "serve", Args: []string{"--port", "8080"}, Direct: true}}
+	if !reflect.DeepEqual(ctx.processes, want) {
+		t.Errorf("processes = %v, want %v", ctx.processes, want)
+	}
+	if len(ctx.buildpackPlans) != 1 || ctx.buildpackPlans[0].Name != "dep" {
+		t.Errorf("buildpack plans = %v, want [dep]", ctx.buildpackPlans)
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// diagnosticsDir is the directory under BUILDER_OUTPUT in which diagnostics bundles are written.
+	diagnosticsDir = "diagnostics"
+	// versionTimeout bounds the time spent detecting each tool version.
+	versionTimeout = 10 * time.Second
+)
+
+var (
+	// toolVersionCmds are run to detect the versions of language tools, if present on PATH.
+	toolVersionCmds = [][]string{
+		{"node", "--version"},
+		{"go", "version"},
+		{"java", "-version"},
+		{"python3", "--version"},
+		{"dotnet", "--version"},
+	}
+
+	// diagnosticsConfigVars are the GOOGLE_* variables whose values are written to the diagnostics bundle. Other
+	// variables, e.g. build arguments, linker flags or an entrypoint, may hold secrets and only their names are written.
+	diagnosticsConfigVars = map[string]bool{
+		env.Runtime:               true,
+		env.RuntimeVersion:        true,
+		env.DebugMode:             true,
+		env.DevMode:               true,
+		env.ClearSource:           true,
+		env.Buildable:             true,
+		env.FunctionTarget:        true,
+		env.FunctionSource:        true,
+		env.FunctionSignatureType: true,
+		env.JavaJlink:             true,
+		env.NodeRunScripts:        true,
+		env.PHPWebServer:          true,
+		env.PHPDocumentRoot:       true,
+		env.MaxLaunchSize:         true,
+	}
+)
+
+// failedExec is a command that exited with a non-zero exit code.
+type failedExec struct {
+	cmd    []string
+	result *ExecResult
+}
+
+// diagnosticsSpan is the serialized form of a span.
+type diagnosticsSpan struct {
+	Name       string                 `json:"name"`
+	Start      time.Time              `json:"start"`
+	End        time.Time              `json:"end"`
+	Status     Status                 `json:"status"`
+	Attributes map[string]interface{} `json:"attributes,omitempty"`
+}
+
+// saveDiagnostics writes a bundle of information useful to triage a failed build under BUILDER_OUTPUT, if set.
+// The bundle contains the error, the full output of the last failed command, the non-secret GOOGLE_* configuration,
+// versions of language tools, layer metadata and spans. Failures to write the bundle are only logged.
+func (ctx *Context) saveDiagnostics(be *Error) {
+	outputDir := os.Getenv(builderOutputEnv)
+	if outputDir == "" {
+		return
+	}
+	dir := filepath.Join(outputDir, diagnosticsDir, strings.ReplaceAll(ctx.BuildpackID(), "/", "_"))
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		ctx.Warnf("Failed to create dir %s, skipping diagnostics: %v", dir, err)
+		return
+	}
+
+	files := map[string][]byte{
+		"error.txt":    ctx.diagnosticsError(be),
+		"config.txt":   ctx.diagnosticsConfig(),
+		"versions.txt": diagnosticsVersions(),
+	}
+	if ctx.failedExec != nil {
+		files["command.txt"] = diagnosticsCommand(ctx.failedExec)
+	}
+	if spans, err := ctx.diagnosticsSpans(); err != nil {
+		ctx.Warnf("Failed to marshal spans, skipping: %v", err)
+	} else {
+		files["spans.json"] = spans
+	}
+	for name, data := range files {
+		fname := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(fname, data, 0644); err != nil {
+			ctx.Warnf("Failed to write %s, skipping: %v", fname, err)
+		}
+	}
+	if ctx.b != nil {
+		ctx.copyLayerMetadata(filepath.Join(dir, "layers"))
+	}
+	ctx.Logf("Diagnostics written to %s", dir)
+}
+
+func (ctx *Context) diagnosticsError(be *Error) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Buildpack: %s@%s\n", ctx.BuildpackID(), ctx.BuildpackVersion())
+	if be != nil {
+		fmt.Fprintf(&b, "Status: %s\n", be.Status)
+		fmt.Fprintf(&b, "Error: %s\n", be.Error())
+	}
+	return b.Bytes()
+}
+
+// diagnosticsCommand returns the command and its full, untruncated combined output.
+func diagnosticsCommand(fe *failedExec) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "Command: %s\n", strings.Join(fe.cmd, " "))
+	fmt.Fprintf(&b, "Exit code: %d\n", fe.result.ExitCode)
+	fmt.Fprintf(&b, "%s\n%s\n", divider, fe.result.Combined)
+	return b.Bytes()
+}
+
+// diagnosticsConfig returns the effective GOOGLE_* environment variables, sorted by name. Only the values of
+// diagnosticsConfigVars are included, with secrets redacted.
+func (ctx *Context) diagnosticsConfig() []byte {
+	var vars []string
+	for _, e := range os.Environ() {
+		if !strings.HasPrefix(e, "GOOGLE_") {
+			continue
+		}
+		name := strings.SplitN(e, "=", 2)[0]
+		if !diagnosticsConfigVars[name] {
+			e = name + "=" + redacted
+		}
+		vars = append(vars, ctx.redact(e))
+	}
+	sort.Strings(vars)
+	return []byte(strings.Join(vars, "\n") + "\n")
+}
+
+// diagnosticsVersions returns the versions of the language tools found on PATH.
+// Commands are run directly rather than through ctx.Exec, which exits on failure.
+func diagnosticsVersions() []byte {
+	var b bytes.Buffer
+	for _, cmd := range toolVersionCmds {
+		if _, err := exec.LookPath(cmd[0]); err != nil {
+			continue
+		}
+		c, cancel := context.WithTimeout(context.Background(), versionTimeout)
+		out, err := exec.CommandContext(c, cmd[0], cmd[1:]...).CombinedOutput()
+		cancel()
+		version := strings.TrimSpace(string(out))
+		if err != nil {
+			version = fmt.Sprintf("%s (%v)", version, err)
+		}
+		fmt.Fprintf(&b, "%s: %s\n", strings.Join(cmd, " "), version)
+	}
+	return b.Bytes()
+}
+
+func (ctx *Context) diagnosticsSpans() ([]byte, error) {
+	var spans []diagnosticsSpan
+	for _, s := range ctx.stats.spans {
+		if s == nil {
+			continue
+		}
+		spans = append(spans, diagnosticsSpan{Name: s.name, Start: s.start, End: s.end, Status: s.status, Attributes: s.attributes})
+	}
+	return json.MarshalIndent(spans, "", "  ")
+}
+
+// copyLayerMetadata copies the <layer>.toml metadata files of the buildpack into dir.
+func (ctx *Context) copyLayerMetadata(dir string) {
+	files, err := filepath.Glob(filepath.Join(ctx.b.Layers.Root, "*.toml"))
+	if err != nil || len(files) == 0 {
+		return
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		ctx.Warnf("Failed to create dir %s, skipping layer metadata: %v", dir, err)
+		return
+	}
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			ctx.Warnf("Failed to read %s, skipping: %v", f, err)
+			continue
+		}
+		fname := filepath.Join(dir, filepath.Base(f))
+		if err := ioutil.WriteFile(fname, data, 0644); err != nil {
+			ctx.Warnf("Failed to write %s, skipping: %v", fname, err)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveDiagnostics(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "save-diagnostics-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	os.Setenv("BUILDER_OUTPUT", tempDir)
+	defer os.Unsetenv("BUILDER_OUTPUT")
+	os.Setenv("GOOGLE_DIAGNOSTICS_TEST", "value")
+	defer os.Unsetenv("GOOGLE_DIAGNOSTICS_TEST")
+	os.Setenv("GOOGLE_RUNTIME_VERSION", "14.x")
+	defer os.Unsetenv("GOOGLE_RUNTIME_VERSION")
+
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	// The output is longer than what is kept in the builder output file.
+	longOutput := strings.Repeat("x", 2*maxMessageBytes)
+	cmd := []string{"bash", "-c", "echo first-line; echo " + longOutput + "; echo last-line >&2; exit 3"}
+	result, err := ctx.ExecWithErr(cmd)
+	if err == nil {
+		t.Fatal("ExecWithErr() got no error, want error")
+	}
+	// As recorded by ExecUser before exiting.
+	ctx.recordFailedExec(cmd, result)
+	ctx.saveDiagnostics(UserErrorf("build failed"))
+
+	dir := filepath.Join(tempDir, diagnosticsDir, "my-id")
+	wantContents := map[string][]string{
+		"error.txt":   {"Buildpack: my-id@my-version", "Status: UNKNOWN", "Error: build failed"},
+		"command.txt": {"Exit code: 3", "first-line", longOutput, "last-line"},
+		"config.txt":  {"GOOGLE_DIAGNOSTICS_TEST=[REDACTED]", "GOOGLE_RUNTIME_VERSION=14.x"},
+		"spans.json":  {`"name": "Exec \"bash -c`},
+	}
+	for name, want := range wantContents {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Errorf("reading %s: %v", name, err)
+			continue
+		}
+		for _, w := range want {
+			if !strings.Contains(string(content), w) {
+				t.Errorf("%s does not contain %q, got:\n%s", name, w, content)
+			}
+		}
+	}
+
+	var spans []diagnosticsSpan
+	content, err := ioutil.ReadFile(filepath.Join(dir, "spans.json"))
+	if err != nil {
+		t.Fatalf("reading spans.json: %v", err)
+	}
+	if err := json.Unmarshal(content, &spans); err != nil {
+		t.Fatalf("unmarshalling spans.json: %v", err)
+	}
+	if len(spans) != 1 || spans[0].Status != StatusInternal {
+		t.Errorf("spans.json got %#v, want 1 span with status %v", spans, StatusInternal)
+	}
+}
+
+func TestSaveDiagnosticsRedactsSecrets(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "save-diagnostics-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	os.Setenv("BUILDER_OUTPUT", tempDir)
+	defer os.Unsetenv("BUILDER_OUTPUT")
+
+	os.Setenv("GOOGLE_ENTRYPOINT", "app --token=s3cr3t-t0ken")
+	defer os.Unsetenv("GOOGLE_ENTRYPOINT")
+	os.Setenv("GOOGLE_FUNCTION_TARGET", "s3cr3t-t0ken")
+	defer os.Unsetenv("GOOGLE_FUNCTION_TARGET")
+
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	ctx.RedactSecret("s3cr3t-t0ken")
+
+	cmd := []string{"bash", "-c", "echo s3cr3t-t0ken; exit 1", "s3cr3t-t0ken"}
+	result, err := ctx.ExecWithErr(cmd)
+	if err == nil {
+		t.Fatal("ExecWithErr() got no error, want error")
+	}
+	// As recorded by ExecUser before exiting.
+	ctx.recordFailedExec(cmd, result)
+	ctx.saveDiagnostics(UserErrorf("build failed"))
+
+	for _, name := range []string{"command.txt", "config.txt"} {
+		content, err := ioutil.ReadFile(filepath.Join(tempDir, diagnosticsDir, "my-id", name))
+		if err != nil {
+			t.Fatalf("reading %s: %v", name, err)
+		}
+		if strings.Contains(string(content), "s3cr3t-t0ken") {
+			t.Errorf("%s contains the secret, got:\n%s", name, content)
+		}
+	}
+}
+
+func TestSaveDiagnosticsIgnoresHandledExecFailure(t *testing.T) {
+	tempDir, err := ioutil.TempDir("", "diagnostics-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+	os.Setenv("BUILDER_OUTPUT", tempDir)
+	defer os.Unsetenv("BUILDER_OUTPUT")
+
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	// A probe whose failure is handled by the caller is not the cause of a later failure.
+	if _, err := ctx.ExecWithErr([]string{"bash", "-c", "exit 1"}); err == nil {
+		t.Fatal("ExecWithErr() got no error, want error")
+	}
+	ctx.saveDiagnostics(UserErrorf("unrelated failure"))
+
+	if _, err := os.Stat(filepath.Join(tempDir, diagnosticsDir, "my-id", "command.txt")); !os.IsNotExist(err) {
+		t.Errorf("command.txt exists (err=%v), want no command recorded", err)
+	}
+}
+
+func TestSaveDiagnosticsWithoutBuilderOutput(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	os.Unsetenv("BUILDER_OUTPUT")
+
+	// Must not panic or exit.
+	ctx.saveDiagnostics(UserErrorf("build failed"))
+}
@@ -68,6 +68,7 @@ func (ctx *Context) ExecWithParams(params ExecParams) *ExecResult {
 		} else {
 			be = Errorf(StatusInternal, result.Combined)
 			exitCode = result.ExitCode
+			ctx.recordFailedExec(params.Cmd, result)
 		}
 		be.ID = generateErrorID(params.Cmd...)
 		ctx.Exit(exitCode, be)
@@ -103,6 +104,7 @@ func (ctx *Context) ExecUserWithParams(params ExecParams, esp ErrorSummaryProduc
 			be = Errorf(StatusInternal, err.Error())
 		} else {
 			be = esp(result)
+			ctx.recordFailedExec(params.Cmd, result)
 		}
 		be.ID = generateErrorID(params.Cmd...)
 		ctx.Exit(1, be)
@@ -136,17 +138,20 @@ func (ctx *Context) configuredExec(params ExecParams) (*ExecResult, error) {
 		env := strings.Join(params.Env, " ")
 		readableCmd = fmt.Sprintf("%s (%s)", readableCmd, env)
 	}
+	readableCmd = ctx.redact(readableCmd)
 	optionalLogf(divider)
 	optionalLogf("Running %q", readableCmd)
 
 	status := StatusInternal
+	var usage resourceUsage
 	defer func(start time.Time) {
 		truncated := readableCmd
 		if len(truncated) > 60 {
 			truncated = truncated[:60] + "..."
 		}
 		optionalLogf("Done %q (%v)", truncated, time.Since(start))
-		ctx.Span(ctx.createSpanName(params.Cmd), start, status)
+		ctx.stats.usage.add(usage)
+		ctx.spanWithAttributes(ctx.createSpanName(params.Cmd), start, status, usage.attributes())
 	}(time.Now())
 
 	exitCode := 0
@@ -156,16 +161,35 @@ func (ctx *Context) configuredExec(params ExecParams) (*ExecResult, error) {
 		ecmd.Dir = params.Dir
 	}
 
+	if ctx.Reproducible() {
+		ecmd.Env = append(os.Environ(), reproducibleEnv...)
+	}
 	if len(params.Env) > 0 {
-		ecmd.Env = append(os.Environ(), params.Env...)
+		if ecmd.Env == nil {
+			ecmd.Env = os.Environ()
+		}
+		ecmd.Env = append(ecmd.Env, params.Env...)
 	}
 
 	var outb, errb bytes.Buffer
-	combinedb := lockingBuffer{log: log}
+	combinedb := lockingBuffer{}
+	var rw *redactingWriter
+	if log {
+		combinedb.out = ctx.stderr()
+		if len(ctx.secrets) > 0 {
+			rw = &redactingWriter{ctx: ctx, out: combinedb.out}
+			combinedb.out = rw
+		}
+	}
 	ecmd.Stdout = io.MultiWriter(&outb, &combinedb)
 	ecmd.Stderr = io.MultiWriter(&errb, &combinedb)
 
-	if err := ecmd.Run(); err != nil {
+	err := ecmd.Run()
+	if rw != nil {
+		rw.Flush()
+	}
+	usage = usageOf(ecmd.ProcessState)
+	if err != nil {
 		if ee, ok := err.(*exec.ExitError); ok {
 			// The command returned a non-zero result.
 			exitCode = ee.ExitCode()
@@ -176,9 +200,9 @@ func (ctx *Context) configuredExec(params ExecParams) (*ExecResult, error) {
 
 	result := &ExecResult{
 		ExitCode: exitCode,
-		Stdout:   strings.TrimSpace(string(outb.Bytes())),
-		Stderr:   strings.TrimSpace(string(errb.Bytes())),
-		Combined: strings.TrimSpace(string(combinedb.Bytes())),
+		Stdout:   ctx.redact(strings.TrimSpace(string(outb.Bytes()))),
+		Stderr:   ctx.redact(strings.TrimSpace(string(errb.Bytes()))),
+		Combined: ctx.redact(strings.TrimSpace(string(combinedb.Bytes()))),
 	}
 
 	if exitCode != 0 {
@@ -189,23 +213,43 @@ func (ctx *Context) configuredExec(params ExecParams) (*ExecResult, error) {
 	return result, nil
 }
 
+// recordFailedExec records the command whose failure ends the build, for diagnostics.
+// Failures handled by the caller of ExecWithErr are not recorded.
+func (ctx *Context) recordFailedExec(cmd []string, result *ExecResult) {
+	redacted := make([]string, len(cmd))
+	for i, arg := range cmd {
+		redacted[i] = ctx.redact(arg)
+	}
+	ctx.failedExec = &failedExec{cmd: redacted, result: result}
+}
+
 type lockingBuffer struct {
 	buf bytes.Buffer
 	sync.Mutex
 
-	// log tells the buffer to also log the output to stderr.
-	log bool
+	// out, if set, also receives everything written to the buffer.
+	out io.Writer
 }
 
 func (lb *lockingBuffer) Write(p []byte) (int, error) {
 	lb.Lock()
 	defer lb.Unlock()
-	if lb.log {
-		os.Stderr.Write(p)
+	if lb.out != nil {
+		lb.out.Write(p)
 	}
 	return lb.buf.Write(p)
 }
 
 func (lb *lockingBuffer) Bytes() []byte {
+	lb.Lock()
+	defer lb.Unlock()
 	return lb.buf.Bytes()
 }
+
+// stderr returns the writer for command output: the task buffer within RunConcurrently, otherwise os.Stderr.
+func (ctx *Context) stderr() io.Writer {
+	if ctx.taskOutput != nil {
+		return ctx.taskOutput
+	}
+	return os.Stderr
+}
@@ -53,8 +53,10 @@ type DetectFn func(*Context) error
 type BuildFn func(*Context) error
 
 type stats struct {
-	spans []*spanInfo
-	user  time.Duration
+	spans  []*spanInfo
+	user   time.Duration
+	usage  resourceUsage
+	layers []layerSize
 }
 
 // Context provides contextually aware functions for buildpack authors.
@@ -70,6 +72,15 @@ type Context struct {
 	d               *libdetect.Detect
 	b               *libbuild.Build
 	stats           stats
+	sourceDate      time.Time
+	failedExec      *failedExec
+	secrets         []string
+
+	// taskOutput buffers the output of a concurrent task, see RunConcurrently.
+	taskOutput *lockingBuffer
+	taskLogger *log.Logger
+	// task is true for the context of a concurrent task, which must not exit the process.
+	task bool
 }
 
 // NewContext creates a context.
@@ -79,9 +90,15 @@ func NewContext(info buildpack.Info) *Context {
 		logger.Printf("Failed to parse debug mode: %v", err)
 		os.Exit(1)
 	}
+	sourceDate, _, err := env.SourceDate()
+	if err != nil {
+		logger.Printf("Failed to parse source date: %v", err)
+		os.Exit(1)
+	}
 	return &Context{
-		debug: debug,
-		info:  info,
+		debug:      debug,
+		info:       info,
+		sourceDate: sourceDate,
 	}
 }
 
@@ -92,6 +109,13 @@ func NewContextForTests(info buildpack.Info, root string) *Context {
 	return ctx
 }
 
+// NewContextForTestsWithLayers creates a context to be used for tests, whose layers are created in layersDir.
+func NewContextForTestsWithLayers(info buildpack.Info, root, layersDir string) *Context {
+	ctx := NewContextForTests(info, root)
+	ctx.b = &libbuild.Build{Layers: layers.Layers{Root: layersDir}}
+	return ctx
+}
+
 func newDetectContext() *Context {
 	d, err := libdetect.DefaultDetect()
 	if err != nil {
@@ -207,6 +231,9 @@ func build(b BuildFn) {
 		ctx.Exit(ctx.b.Failure(1), Errorf(status, msg))
 	}
 
+	ctx.normalizeLayers()
+	ctx.accountLayers()
+
 	// Emit application metadata.
 	if len(ctx.processes) > 0 {
 		metadata := layers.Metadata{Processes: ctx.processes}
@@ -225,12 +252,17 @@ func build(b BuildFn) {
 
 // Exit causes the buildpack to exit with the given exit code and message.
 func (ctx *Context) Exit(exitCode int, be *Error) {
+	if ctx.task {
+		// Unwind to runTask so that the error is returned once all tasks complete.
+		panic(taskExit{exitCode: exitCode, err: be})
+	}
 	if be != nil {
 		ctx.Logf("Failure: " + be.Message)
 		ctx.saveErrorOutput(be)
 	}
 
 	if exitCode != 0 {
+		ctx.saveDiagnostics(be)
 		ctx.Tipf(divider)
 		ctx.Tipf(`Sorry your project couldn't be built.`)
 		ctx.Tipf(`Our documentation explains ways to configure Buildpacks to better recognise your project:`)
@@ -258,6 +290,10 @@ func (ctx *Context) OptIn(format string, args ...interface{}) {
 
 // Logf emits a structured logging line.
 func (ctx *Context) Logf(format string, args ...interface{}) {
+	if ctx.taskLogger != nil {
+		ctx.taskLogger.Printf(format, args...)
+		return
+	}
 	logger.Printf(format, args...)
 }
 
@@ -294,12 +330,20 @@ func (ctx *Context) CacheMiss(tag string) {
 
 // Span emits a structured Stackdriver span.
 func (ctx *Context) Span(label string, start time.Time, status Status) {
+	ctx.spanWithAttributes(label, start, status, nil)
+}
+
+// spanWithAttributes emits a structured Stackdriver span with additional attributes.
+func (ctx *Context) spanWithAttributes(label string, start time.Time, status Status, extra map[string]interface{}) {
 	now := time.Now()
 	attributes := map[string]interface{}{
 		"/buildpack_id":      ctx.BuildpackID(),
 		"/buildpack_name":    ctx.BuildpackName(),
 		"/buildpack_version": ctx.BuildpackVersion(),
 	}
+	for k, v := range extra {
+		attributes[k] = v
+	}
 	si, err := newSpanInfo(label, start, now, attributes, status)
 	if err != nil {
 		ctx.Logf("Warning: invalid span dropped: %v", err)
@@ -324,15 +368,6 @@ func (ctx *Context) AddBuildpackPlan(plan buildpackplan.Plan) {
 
 // AddWebProcess adds the given command as the web start process, overwriting any previous web start process.
 func (ctx *Context) AddWebProcess(cmd []string) {
-	current := ctx.processes
-	ctx.processes = layers.Processes{}
-	for _, p := range current {
-		if p.Type == "web" {
-			ctx.Logf("Warning: overwriting existing web process %q.", p.Command)
-			continue // Do not add this item back to the ctx.processes; we are overwriting it.
-		}
-		ctx.processes = append(ctx.processes, p)
-	}
 	p := layers.Process{
 		Type:    "web",
 		Command: cmd[0],
@@ -341,6 +376,20 @@ func (ctx *Context) AddWebProcess(cmd []string) {
 	if len(cmd) > 1 {
 		p.Args = cmd[1:]
 	}
+	ctx.addProcess(p)
+}
+
+// addProcess adds the given process, overwriting any previous process of the same type.
+func (ctx *Context) addProcess(p layers.Process) {
+	current := ctx.processes
+	ctx.processes = layers.Processes{}
+	for _, c := range current {
+		if c.Type == p.Type {
+			ctx.Logf("Warning: overwriting existing %s process %q.", c.Type, c.Command)
+			continue // Do not add this item back to the ctx.processes; we are overwriting it.
+		}
+		ctx.processes = append(ctx.processes, c)
+	}
 	ctx.processes = append(ctx.processes, p)
 }
 
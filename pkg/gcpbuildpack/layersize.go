@@ -0,0 +1,289 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+const (
+	// maxContributors is the number of largest launch layers named when the launch size budget is exceeded.
+	maxContributors = 3
+	// layerSizeKey is the key of the [metadata] table of <layer>.toml in which the size of a launch layer is recorded,
+	// so that the size of a layer reused from the previous image, whose contents are absent, is known.
+	layerSizeKey = "gcp_layer_size_bytes"
+	// accountedMarker is written to the layers directory of a buildpack once its layer sizes are recorded. Only
+	// buildpacks that ran in this build have it: the lifecycle restores layers and their metadata, not other files.
+	accountedMarker = ".gcp_layers_accounted"
+)
+
+// reservedTOML are files in a buildpack layers directory that do not describe a layer.
+var reservedTOML = map[string]bool{"launch.toml": true, "build.toml": true, "store.toml": true}
+
+// layerSize describes the size of a layer created by the buildpack.
+type layerSize struct {
+	Name      string `json:"name"`
+	SizeBytes int64  `json:"sizeBytes"`
+	Build     bool   `json:"build,omitempty"`
+	Cache     bool   `json:"cache,omitempty"`
+	Launch    bool   `json:"launch,omitempty"`
+}
+
+// layerFlags mirrors the flags libbuildpack writes to <layer>.toml, and the recorded size of the layer.
+type layerFlags struct {
+	Build    bool `toml:"build"`
+	Cache    bool `toml:"cache"`
+	Launch   bool `toml:"launch"`
+	Metadata struct {
+		SizeBytes int64 `toml:"gcp_layer_size_bytes"`
+	} `toml:"metadata"`
+}
+
+// measureLayers returns the sizes of the layers in layersDir, largest first.
+// Only layers with a metadata file are layers; other directories are discarded by the lifecycle. The size of a layer
+// without contents, e.g. a launch layer reused from the previous image, is the size recorded in its metadata. If
+// preferRecorded is true, the recorded size is used whenever present, e.g. for layers of buildpacks that already ran.
+func measureLayers(layersDir string, preferRecorded bool) ([]layerSize, error) {
+	files, err := ioutil.ReadDir(layersDir)
+	if err != nil {
+		return nil, fmt.Errorf("reading layers directory %s: %v", layersDir, err)
+	}
+	var sizes []layerSize
+	for _, f := range files {
+		if f.IsDir() || filepath.Ext(f.Name()) != ".toml" || reservedTOML[f.Name()] {
+			continue
+		}
+		name := strings.TrimSuffix(f.Name(), ".toml")
+		metadata := filepath.Join(layersDir, f.Name())
+		var flags layerFlags
+		if _, err := toml.DecodeFile(metadata, &flags); err != nil {
+			return nil, fmt.Errorf("decoding %s: %v", metadata, err)
+		}
+		size := flags.Metadata.SizeBytes
+		dir := filepath.Join(layersDir, name)
+		if fi, err := os.Stat(dir); err == nil && fi.IsDir() && (!preferRecorded || size == 0) {
+			if size, err = dirSize(dir); err != nil {
+				return nil, err
+			}
+		}
+		sizes = append(sizes, layerSize{Name: name, SizeBytes: size, Build: flags.Build, Cache: flags.Cache, Launch: flags.Launch})
+	}
+	sort.SliceStable(sizes, func(i, j int) bool {
+		return sizes[i].SizeBytes > sizes[j].SizeBytes
+	})
+	return sizes, nil
+}
+
+// dirSize returns the total size of regular files under root. Symlinks are not followed.
+func dirSize(root string) (int64, error) {
+	var size int64
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.Mode().IsRegular() {
+			size += info.Size()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("walking %s: %v", root, err)
+	}
+	return size, nil
+}
+
+// recordLayerSizes records the size of each launch layer with contents in its metadata file, and marks the layers
+// directory as accounted for in this build.
+func recordLayerSizes(layersDir string, sizes []layerSize) error {
+	for _, s := range sizes {
+		if !s.Launch {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(layersDir, s.Name)); err != nil {
+			// The layer is reused from the previous image and keeps the size recorded then.
+			continue
+		}
+		metadata := filepath.Join(layersDir, s.Name+".toml")
+		var content map[string]interface{}
+		if _, err := toml.DecodeFile(metadata, &content); err != nil {
+			return fmt.Errorf("decoding %s: %v", metadata, err)
+		}
+		m, ok := content["metadata"].(map[string]interface{})
+		if !ok {
+			m = map[string]interface{}{}
+			content["metadata"] = m
+		}
+		m[layerSizeKey] = s.SizeBytes
+		var b bytes.Buffer
+		if err := toml.NewEncoder(&b).Encode(content); err != nil {
+			return fmt.Errorf("encoding %s: %v", metadata, err)
+		}
+		if err := ioutil.WriteFile(metadata, b.Bytes(), 0644); err != nil {
+			return fmt.Errorf("writing %s: %v", metadata, err)
+		}
+	}
+	marker := filepath.Join(layersDir, accountedMarker)
+	if err := ioutil.WriteFile(marker, nil, 0644); err != nil {
+		return fmt.Errorf("writing %s: %v", marker, err)
+	}
+	return nil
+}
+
+// imageLaunchLayers returns the launch layers of the image built so far: those of the buildpacks that already ran in
+// this build, whose layers directories are siblings of layersDir marked as accounted, and own, the layers of the
+// current buildpack. Layers restored for buildpacks that have not run yet and other directories are not counted.
+// Layer names are qualified with the name of the buildpack layers directory.
+func imageLaunchLayers(layersDir string, own []layerSize) ([]layerSize, error) {
+	root := filepath.Dir(layersDir)
+	files, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("reading layers root %s: %v", root, err)
+	}
+	var launch []layerSize
+	add := func(bp string, sizes []layerSize) {
+		for _, s := range sizes {
+			if s.Launch {
+				s.Name = bp + "/" + s.Name
+				launch = append(launch, s)
+			}
+		}
+	}
+	self := filepath.Base(layersDir)
+	for _, f := range files {
+		if !f.IsDir() || f.Name() == self {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(root, f.Name(), accountedMarker)); err != nil {
+			continue
+		}
+		sizes, err := measureLayers(filepath.Join(root, f.Name()), true)
+		if err != nil {
+			return nil, err
+		}
+		add(f.Name(), sizes)
+	}
+	add(self, own)
+	sort.SliceStable(launch, func(i, j int) bool {
+		return launch[i].SizeBytes > launch[j].SizeBytes
+	})
+	return launch, nil
+}
+
+// checkLaunchBudget returns an error naming the largest launch layers if their total size exceeds max bytes.
+func checkLaunchBudget(sizes []layerSize, max int64) *Error {
+	var total int64
+	var contributors []string
+	for _, s := range sizes {
+		if !s.Launch {
+			continue
+		}
+		total += s.SizeBytes
+		if len(contributors) < maxContributors {
+			contributors = append(contributors, fmt.Sprintf("%s (%s)", s.Name, formatSize(s.SizeBytes)))
+		}
+	}
+	if total <= max {
+		return nil
+	}
+	return Errorf(StatusResourceExhausted, "launch layers of the image total %s, exceeding %s=%s; largest layers: %s",
+		formatSize(total), env.MaxLaunchSize, formatSize(max), strings.Join(contributors, ", "))
+}
+
+// parseSize parses a size in bytes, optionally suffixed with K, M or G.
+func parseSize(s string) (int64, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	multiplier := int64(1)
+	for i, suffix := range []string{"K", "M", "G"} {
+		if strings.HasSuffix(s, suffix) {
+			multiplier = int64(1) << (10 * uint(i+1))
+			s = strings.TrimSuffix(s, suffix)
+			break
+		}
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * multiplier, nil
+}
+
+// formatSize formats a size in bytes for humans.
+func formatSize(b int64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%dB", b)
+	}
+	div, exp := int64(unit), 0
+	for n := b / unit; n >= unit && exp < 2; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f%c", float64(b)/float64(div), "KMG"[exp])
+}
+
+// accountLayers logs the size of each layer, records it in the stats and the layer metadata, and enforces the launch
+// size budget on the launch layers of all buildpacks that have run so far.
+func (ctx *Context) accountLayers() {
+	sizes, err := measureLayers(ctx.b.Layers.Root, false)
+	if err != nil {
+		ctx.Exit(1, InternalErrorf("measuring layers: %v", err))
+	}
+	if err := recordLayerSizes(ctx.b.Layers.Root, sizes); err != nil {
+		ctx.Exit(1, InternalErrorf("recording layer sizes: %v", err))
+	}
+	ctx.stats.layers = sizes
+	if len(sizes) > 0 {
+		ctx.Logf("Layer sizes:")
+		for _, s := range sizes {
+			var flags []string
+			if s.Build {
+				flags = append(flags, "build")
+			}
+			if s.Cache {
+				flags = append(flags, "cache")
+			}
+			if s.Launch {
+				flags = append(flags, "launch")
+			}
+			ctx.Logf("  %-30s %10s  %s", s.Name, formatSize(s.SizeBytes), strings.Join(flags, ","))
+		}
+	}
+
+	v, ok := os.LookupEnv(env.MaxLaunchSize)
+	if !ok {
+		return
+	}
+	max, err := parseSize(v)
+	if err != nil {
+		ctx.Exit(1, UserErrorf("parsing %s: %v", env.MaxLaunchSize, err))
+	}
+	launch, err := imageLaunchLayers(ctx.b.Layers.Root, sizes)
+	if err != nil {
+		ctx.Exit(1, InternalErrorf("measuring image layers: %v", err))
+	}
+	if be := checkLaunchBudget(launch, max); be != nil {
+		ctx.Exit(1, be)
+	}
+}
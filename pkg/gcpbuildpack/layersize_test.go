@@ -0,0 +1,255 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+)
+
+func TestMeasureLayers(t *testing.T) {
+	layersDir, err := ioutil.TempDir("", "layers-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layersDir)
+
+	files := map[string]string{
+		"deps.toml":          "launch = true\ncache = true\n",
+		"deps/lib/a":         strings.Repeat("a", 3000),
+		"deps/lib/b":         strings.Repeat("b", 1000),
+		"runtime.toml":       "launch = true\nbuild = true\n",
+		"runtime/bin/node":   strings.Repeat("n", 5000),
+		"cache.toml":         "cache = true\n",
+		"cache/blob":         strings.Repeat("c", 100),
+		"no-metadata/ignore": strings.Repeat("x", 10000),
+		"reused.toml":        "launch = true\n[metadata]\n  gcp_layer_size_bytes = 2000\n",
+		"launch.toml":        "[[processes]]\n  type = \"web\"\n",
+	}
+	for f, c := range files {
+		fn := filepath.Join(layersDir, f)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", fn, err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(c), 0644); err != nil {
+			t.Fatalf("writing file %s: %v", fn, err)
+		}
+	}
+	if err := os.Symlink("/usr", filepath.Join(layersDir, "deps", "usr")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+
+	got, err := measureLayers(layersDir, false)
+	if err != nil {
+		t.Fatalf("measureLayers() got error: %v", err)
+	}
+
+	want := []layerSize{
+		{Name: "runtime", SizeBytes: 5000, Build: true, Launch: true},
+		{Name: "deps", SizeBytes: 4000, Cache: true, Launch: true},
+		{Name: "reused", SizeBytes: 2000, Launch: true},
+		{Name: "cache", SizeBytes: 100, Cache: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("measureLayers() got %#v, want %#v", got, want)
+	}
+}
+
+func TestImageLaunchLayers(t *testing.T) {
+	root, err := ioutil.TempDir("", "layers-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	files := map[string]string{
+		"runtime/python.toml":  "launch = true\n",
+		"runtime/python/bin":   strings.Repeat("p", 3000),
+		"runtime/reused.toml":  "launch = true\n[metadata]\n  gcp_layer_size_bytes = 500\n",
+		"runtime/cache.toml":   "cache = true\n",
+		"runtime/cache/blob":   strings.Repeat("c", 9000),
+		"pip/deps.toml":        "launch = true\ncache = true\n[metadata]\n  python_version = \"3.8\"\n",
+		"pip/deps/lib/six.py":  strings.Repeat("s", 1000),
+		"config/metadata.toml": "",
+		// Restored for a buildpack that has not run yet, which may not reuse it.
+		"entrypoint/web.toml": "launch = true\n",
+		"entrypoint/web/bin":  strings.Repeat("e", 5000),
+		// Not a buildpack layers directory.
+		"tmp/launch.toml": "launch = true\n",
+		"tmp/launch/data": strings.Repeat("t", 5000),
+	}
+	for f, c := range files {
+		fn := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", fn, err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(c), 0644); err != nil {
+			t.Fatalf("writing file %s: %v", fn, err)
+		}
+	}
+
+	// The runtime buildpack ran first and recorded the size of its launch layers.
+	runtimeDir := filepath.Join(root, "runtime")
+	runtimeSizes, err := measureLayers(runtimeDir, false)
+	if err != nil {
+		t.Fatalf("measureLayers(%q) got error: %v", runtimeDir, err)
+	}
+	if err := recordLayerSizes(runtimeDir, runtimeSizes); err != nil {
+		t.Fatalf("recordLayerSizes(%q) got error: %v", runtimeDir, err)
+	}
+	// The recorded size is used for buildpacks that already ran, e.g. if the layer contents are absent.
+	if err := os.RemoveAll(filepath.Join(runtimeDir, "python")); err != nil {
+		t.Fatalf("removing layer contents: %v", err)
+	}
+
+	pipDir := filepath.Join(root, "pip")
+	pipSizes, err := measureLayers(pipDir, false)
+	if err != nil {
+		t.Fatalf("measureLayers(%q) got error: %v", pipDir, err)
+	}
+	got, err := imageLaunchLayers(pipDir, pipSizes)
+	if err != nil {
+		t.Fatalf("imageLaunchLayers() got error: %v", err)
+	}
+
+	want := []layerSize{
+		{Name: "runtime/python", SizeBytes: 3000, Launch: true},
+		{Name: "pip/deps", SizeBytes: 1000, Cache: true, Launch: true},
+		{Name: "runtime/reused", SizeBytes: 500, Launch: true},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("imageLaunchLayers() got %#v, want %#v", got, want)
+	}
+
+	if err := recordLayerSizes(pipDir, pipSizes); err != nil {
+		t.Fatalf("recordLayerSizes(%q) got error: %v", pipDir, err)
+	}
+	var meta struct {
+		Metadata map[string]interface{} `toml:"metadata"`
+	}
+	if _, err := toml.DecodeFile(filepath.Join(pipDir, "deps.toml"), &meta); err != nil {
+		t.Fatalf("decoding deps.toml: %v", err)
+	}
+	if meta.Metadata["python_version"] != "3.8" || meta.Metadata[layerSizeKey] != int64(1000) {
+		t.Errorf("deps.toml metadata = %v, want buildpack metadata preserved and size recorded", meta.Metadata)
+	}
+}
+
+func TestCheckLaunchBudget(t *testing.T) {
+	sizes := []layerSize{
+		{Name: "cache", SizeBytes: 100 << 20, Cache: true},
+		{Name: "runtime", SizeBytes: 50 << 20, Launch: true},
+		{Name: "deps", SizeBytes: 30 << 20, Launch: true},
+		{Name: "config", SizeBytes: 1 << 10, Launch: true},
+		{Name: "tiny", SizeBytes: 10, Launch: true},
+	}
+	testCases := []struct {
+		name    string
+		max     int64
+		wantErr bool
+	}{
+		{
+			name: "within budget",
+			max:  100 << 20,
+		},
+		{
+			name:    "over budget",
+			max:     60 << 20,
+			wantErr: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got := checkLaunchBudget(sizes, tc.max)
+
+			if got == nil {
+				if tc.wantErr {
+					t.Fatalf("checkLaunchBudget() got no error, want error")
+				}
+				return
+			}
+			if !tc.wantErr {
+				t.Fatalf("checkLaunchBudget() got error %v, want no error", got)
+			}
+			if got.Status != StatusResourceExhausted {
+				t.Errorf("checkLaunchBudget() got status %v, want %v", got.Status, StatusResourceExhausted)
+			}
+			for _, s := range []string{"runtime (50.0M)", "deps (30.0M)", "config (1.0K)"} {
+				if !strings.Contains(got.Message, s) {
+					t.Errorf("checkLaunchBudget() message %q does not contain %q", got.Message, s)
+				}
+			}
+			for _, s := range []string{"cache", "tiny"} {
+				if strings.Contains(got.Message, s) {
+					t.Errorf("checkLaunchBudget() message %q unexpectedly contains %q", got.Message, s)
+				}
+			}
+		})
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	testCases := []struct {
+		value   string
+		want    int64
+		wantErr bool
+	}{
+		{value: "1024", want: 1024},
+		{value: "10K", want: 10 << 10},
+		{value: "500m", want: 500 << 20},
+		{value: " 2G ", want: 2 << 30},
+		{value: "", wantErr: true},
+		{value: "-1", wantErr: true},
+		{value: "1.5G", wantErr: true},
+		{value: "lots", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			got, err := parseSize(tc.value)
+
+			if err != nil != tc.wantErr {
+				t.Fatalf("parseSize(%q) got err=%v, want err=%t", tc.value, err, tc.wantErr)
+			}
+			if got != tc.want {
+				t.Errorf("parseSize(%q)=%d, want %d", tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	testCases := []struct {
+		size int64
+		want string
+	}{
+		{size: 0, want: "0B"},
+		{size: 1023, want: "1023B"},
+		{size: 1536, want: "1.5K"},
+		{size: 200 << 20, want: "200.0M"},
+		{size: 3 << 30, want: "3.0G"},
+		{size: 2048 << 30, want: "2048.0G"},
+	}
+	for _, tc := range testCases {
+		if got := formatSize(tc.size); got != tc.want {
+			t.Errorf("formatSize(%d)=%q, want %q", tc.size, got, tc.want)
+		}
+	}
+}
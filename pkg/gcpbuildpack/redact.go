@@ -0,0 +1,68 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"io"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// RedactSecret replaces the secret with [REDACTED] in the logged command line and output, the results and the
+// diagnostics of commands run from now on.
+func (ctx *Context) RedactSecret(secret string) {
+	if secret == "" {
+		return
+	}
+	ctx.secrets = append(ctx.secrets, secret)
+}
+
+// redact returns s with all secrets replaced.
+func (ctx *Context) redact(s string) string {
+	for _, secret := range ctx.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+// redactingWriter writes complete lines to out with secrets redacted, so that a secret split across writes is redacted.
+type redactingWriter struct {
+	ctx *Context
+	out io.Writer
+	buf []byte
+}
+
+func (w *redactingWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	if i := bytes.LastIndexByte(w.buf, '\n'); i >= 0 {
+		if _, err := io.WriteString(w.out, w.ctx.redact(string(w.buf[:i+1]))); err != nil {
+			return 0, err
+		}
+		w.buf = w.buf[i+1:]
+	}
+	return len(p), nil
+}
+
+// Flush writes the remaining partial line.
+func (w *redactingWriter) Flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := io.WriteString(w.out, w.ctx.redact(string(w.buf)))
+	w.buf = nil
+	return err
+}
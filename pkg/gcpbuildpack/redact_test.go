@@ -0,0 +1,69 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestRedactingWriter(t *testing.T) {
+	ctx := &Context{}
+	ctx.RedactSecret("s3cr3t-t0ken")
+	ctx.RedactSecret("")
+
+	var out bytes.Buffer
+	w := &redactingWriter{ctx: ctx, out: &out}
+	// The secret is split across writes.
+	for _, p := range []string{"//registry/:_authToken=s3cr", "3t-t0ken\nfetching ", "s3cr3t-t0ken"} {
+		if _, err := w.Write([]byte(p)); err != nil {
+			t.Fatalf("Write(%q) got error: %v", p, err)
+		}
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() got error: %v", err)
+	}
+
+	want := "//registry/:_authToken=[REDACTED]\nfetching [REDACTED]"
+	if got := out.String(); got != want {
+		t.Errorf("redactingWriter wrote %q, want %q", got, want)
+	}
+}
+
+func TestExecRedactsSecrets(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+	ctx.RedactSecret("s3cr3t-t0ken")
+
+	result, err := ctx.ExecWithErr([]string{"/bin/bash", "-c", "echo token=s3cr3t-t0ken; echo s3cr3t-t0ken >&2; exit 1"})
+	if err == nil {
+		t.Fatal("ExecWithErr() got no error, want exit code 1")
+	}
+	for name, got := range map[string]string{
+		"stdout":   result.Stdout,
+		"stderr":   result.Stderr,
+		"combined": result.Combined,
+		"error":    err.Error(),
+		"span":     ctx.stats.spans[0].name,
+	} {
+		if strings.Contains(got, "s3cr3t-t0ken") {
+			t.Errorf("%s %q contains the secret", name, got)
+		}
+	}
+	if result.Stdout != "token=[REDACTED]" {
+		t.Errorf("stdout = %q, want %q", result.Stdout, "token=[REDACTED]")
+	}
+}
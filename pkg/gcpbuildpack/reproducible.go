@@ -0,0 +1,107 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+var (
+	// reproducibleEnv is added to the environment of every command when SOURCE_DATE_EPOCH is set.
+	// SOURCE_DATE_EPOCH itself is inherited by commands, which is enough for tools that honor it,
+	// e.g. Python 3.7+ writes hash-based rather than timestamp-based .pyc files.
+	reproducibleEnv = []string{
+		// Fixes set and dict ordering, which otherwise leaks into marshalled .pyc files.
+		"PYTHONHASHSEED=0",
+		// Timestamps rendered into generated files do not depend on the machine's timezone.
+		"TZ=UTC",
+	}
+)
+
+// Reproducible returns true if SOURCE_DATE_EPOCH is set and layer contents should be reproducible.
+func (ctx *Context) Reproducible() bool {
+	return !ctx.sourceDate.IsZero()
+}
+
+// SourceDate returns the time used to normalize timestamps when the build is reproducible.
+func (ctx *Context) SourceDate() time.Time {
+	return ctx.sourceDate
+}
+
+// atFDCWD and atSymlinkNoFollow are the utimensat(2) arguments that resolve a path relative to the working directory
+// and update the times of a symlink rather than its target.
+const (
+	atFDCWD           = -0x64
+	atSymlinkNoFollow = 0x100
+)
+
+// lutimes sets the access and modification times of path to t without following symlinks.
+func lutimes(path string, t time.Time) error {
+	p, err := syscall.BytePtrFromString(path)
+	if err != nil {
+		return err
+	}
+	ts := []syscall.Timespec{syscall.NsecToTimespec(t.UnixNano()), syscall.NsecToTimespec(t.UnixNano())}
+	dirfd := atFDCWD
+	if _, _, errno := syscall.Syscall6(syscall.SYS_UTIMENSAT, uintptr(dirfd), uintptr(unsafe.Pointer(p)), uintptr(unsafe.Pointer(&ts[0])), atSymlinkNoFollow, 0, 0); errno != 0 {
+		return &os.PathError{Op: "utimensat", Path: path, Err: errno}
+	}
+	return nil
+}
+
+// normalizeTimestamps sets the access and modification times of every file, symlink and directory under root to t.
+func normalizeTimestamps(root string, t time.Time) error {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			// Directories are updated after their contents, which would otherwise bump their mtime.
+			dirs = append(dirs, path)
+			return nil
+		}
+		return lutimes(path, t)
+	})
+	if err != nil {
+		return fmt.Errorf("walking %s: %v", root, err)
+	}
+	for i := len(dirs) - 1; i >= 0; i-- {
+		if err := os.Chtimes(dirs[i], t, t); err != nil {
+			return fmt.Errorf("setting times of %s: %v", dirs[i], err)
+		}
+	}
+	return nil
+}
+
+// normalizeLayers normalizes timestamps in the layers and application directories if the build is reproducible.
+func (ctx *Context) normalizeLayers() {
+	if !ctx.Reproducible() {
+		return
+	}
+	ctx.Debugf("Normalizing timestamps to %s=%d", env.SourceDateEpoch, ctx.sourceDate.Unix())
+	for _, dir := range []string{ctx.b.Layers.Root, ctx.ApplicationRoot()} {
+		if err := normalizeTimestamps(dir, ctx.sourceDate); err != nil {
+			ctx.Exit(1, InternalErrorf("normalizing timestamps: %v", err))
+		}
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+)
+
+func TestRebuildYieldsIdenticalLayerChecksum(t *testing.T) {
+	sourceDate := time.Unix(1589480000, 0)
+	testCases := []struct {
+		name      string
+		normalize bool
+		wantSame  bool
+	}{
+		{
+			name:      "normalized",
+			normalize: true,
+			wantSame:  true,
+		},
+		{
+			name:     "not normalized",
+			wantSame: false,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var sums []string
+			for i := 0; i < 2; i++ {
+				root, err := ioutil.TempDir("", "layer-")
+				if err != nil {
+					t.Fatalf("creating temp dir: %v", err)
+				}
+				defer os.RemoveAll(root)
+
+				// Simulate two builds at different times producing the same content.
+				buildTime := time.Now().Add(time.Duration(i) * time.Hour)
+				writeLayer(t, root, buildTime)
+				if tc.normalize {
+					if err := normalizeTimestamps(root, sourceDate); err != nil {
+						t.Fatalf("normalizeTimestamps(%q) got error: %v", root, err)
+					}
+				}
+				sums = append(sums, layerChecksum(t, root))
+			}
+
+			if same := sums[0] == sums[1]; same != tc.wantSame {
+				t.Errorf("layer checksums %q and %q: same=%t, want %t", sums[0], sums[1], same, tc.wantSame)
+			}
+		})
+	}
+}
+
+func TestNormalizeTimestampsSymlinks(t *testing.T) {
+	root, err := ioutil.TempDir("", "layer-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(root)
+
+	target := filepath.Join(root, "target")
+	if err := ioutil.WriteFile(target, nil, 0644); err != nil {
+		t.Fatalf("writing file: %v", err)
+	}
+	if err := os.Symlink(target, filepath.Join(root, "link")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	// A dangling symlink would fail if it were followed.
+	if err := os.Symlink(filepath.Join(root, "missing"), filepath.Join(root, "dangling")); err != nil {
+		t.Fatalf("creating symlink: %v", err)
+	}
+	want := time.Unix(1589480000, 0)
+	if err := normalizeTimestamps(root, want); err != nil {
+		t.Fatalf("normalizeTimestamps(%q) got error: %v", root, err)
+	}
+	for _, f := range []string{"target", "link", "dangling"} {
+		fi, err := os.Lstat(filepath.Join(root, f))
+		if err != nil {
+			t.Fatalf("lstat %s: %v", f, err)
+		}
+		if got := fi.ModTime(); !got.Equal(want) {
+			t.Errorf("mtime of %s = %v, want %v", f, got, want)
+		}
+	}
+}
+
+func TestExecSetsReproducibleEnv(t *testing.T) {
+	testCases := []struct {
+		name       string
+		sourceDate string
+		want       string
+	}{
+		{
+			name: "not reproducible",
+			want: "",
+		},
+		{
+			name:       "reproducible",
+			sourceDate: "1589480000",
+			want:       "0",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.sourceDate != "" {
+				if err := os.Setenv(env.SourceDateEpoch, tc.sourceDate); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func() {
+					if err := os.Unsetenv(env.SourceDateEpoch); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}()
+			}
+			if err := os.Unsetenv("PYTHONHASHSEED"); err != nil {
+				t.Fatalf("Failed to unset env: %v", err)
+			}
+			ctx, cleanUp := simpleContext(t)
+			defer cleanUp()
+
+			result := ctx.ExecWithParams(ExecParams{
+				Cmd: []string{"/bin/bash", "-c", "echo -n $PYTHONHASHSEED"},
+				Env: []string{"UNRELATED=value"},
+			})
+
+			if result.Stdout != tc.want {
+				t.Errorf("PYTHONHASHSEED=%q, want %q", result.Stdout, tc.want)
+			}
+		})
+	}
+}
+
+// writeLayer creates the same layer contents under root, with timestamps set to t.
+func writeLayer(t *testing.T, root string, ts time.Time) {
+	t.Helper()
+	files := map[string]string{
+		"bin/app":                "binary",
+		"lib/python/mod.py":      "print('hello')",
+		"lib/python/mod.pyc":     "bytecode",
+		".googleconfig/app.json": `{"entrypoint":"/serve"}`,
+	}
+	for f, c := range files {
+		fn := filepath.Join(root, f)
+		if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+			t.Fatalf("creating directory for %s: %v", fn, err)
+		}
+		if err := ioutil.WriteFile(fn, []byte(c), 0644); err != nil {
+			t.Fatalf("writing file %s: %v", fn, err)
+		}
+	}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		return os.Chtimes(path, ts, ts)
+	})
+	if err != nil {
+		t.Fatalf("setting times under %s: %v", root, err)
+	}
+}
+
+// layerChecksum hashes the relative paths, modes, modification times and contents of files under root,
+// which is what determines the digest of an exported layer tarball.
+func layerChecksum(t *testing.T, root string) string {
+	t.Helper()
+	h := sha256.New()
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(h, "%s %v %d\n", rel, info.Mode(), info.ModTime().Unix())
+		if info.Mode().IsRegular() {
+			b, err := ioutil.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			h.Write(b)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("computing checksum of %s: %v", root, err)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
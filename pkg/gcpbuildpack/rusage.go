@@ -0,0 +1,72 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// resourceUsage is the resource usage of one or more child processes.
+type resourceUsage struct {
+	userCPU   time.Duration
+	systemCPU time.Duration
+	// maxRSSKB is the maximum resident set size in kilobytes.
+	maxRSSKB int64
+	// inBlocks and outBlocks are the number of block input and output operations.
+	inBlocks  int64
+	outBlocks int64
+}
+
+// usageOf returns the resource usage of an exited process, as reported by getrusage(2).
+func usageOf(ps *os.ProcessState) resourceUsage {
+	if ps == nil {
+		return resourceUsage{}
+	}
+	ru, ok := ps.SysUsage().(*syscall.Rusage)
+	if !ok || ru == nil {
+		return resourceUsage{}
+	}
+	return resourceUsage{
+		userCPU:   time.Duration(ru.Utime.Nano()),
+		systemCPU: time.Duration(ru.Stime.Nano()),
+		maxRSSKB:  int64(ru.Maxrss),
+		inBlocks:  int64(ru.Inblock),
+		outBlocks: int64(ru.Oublock),
+	}
+}
+
+// add aggregates the usage of another process: CPU time and I/O are summed, the maximum RSS is kept.
+func (u *resourceUsage) add(o resourceUsage) {
+	u.userCPU += o.userCPU
+	u.systemCPU += o.systemCPU
+	u.inBlocks += o.inBlocks
+	u.outBlocks += o.outBlocks
+	if o.maxRSSKB > u.maxRSSKB {
+		u.maxRSSKB = o.maxRSSKB
+	}
+}
+
+// attributes returns the usage as span attributes.
+func (u resourceUsage) attributes() map[string]interface{} {
+	return map[string]interface{}{
+		"/cpu_user_ms":      u.userCPU.Milliseconds(),
+		"/cpu_system_ms":    u.systemCPU.Milliseconds(),
+		"/max_rss_kb":       u.maxRSSKB,
+		"/block_input_ops":  u.inBlocks,
+		"/block_output_ops": u.outBlocks,
+	}
+}
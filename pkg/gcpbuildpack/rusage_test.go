@@ -0,0 +1,56 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpbuildpack
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestResourceUsageAdd(t *testing.T) {
+	u := resourceUsage{userCPU: time.Second, systemCPU: time.Second, maxRSSKB: 100, inBlocks: 1, outBlocks: 2}
+
+	u.add(resourceUsage{userCPU: 2 * time.Second, systemCPU: 3 * time.Second, maxRSSKB: 50, inBlocks: 3, outBlocks: 4})
+
+	want := resourceUsage{userCPU: 3 * time.Second, systemCPU: 4 * time.Second, maxRSSKB: 100, inBlocks: 4, outBlocks: 6}
+	if !reflect.DeepEqual(u, want) {
+		t.Errorf("add() got %#v, want %#v", u, want)
+	}
+}
+
+func TestExecRecordsResourceUsage(t *testing.T) {
+	ctx, cleanUp := simpleContext(t)
+	defer cleanUp()
+
+	// Spin for a while and hold some memory, so that usage is measurable.
+	ctx.Exec([]string{"bash", "-c", `x=$(head -c 20000000 /dev/zero | tr '\0' a); end=$((SECONDS+1)); while [ $SECONDS -lt $end ]; do :; done; echo ${#x}`})
+
+	if len(ctx.stats.spans) != 1 {
+		t.Fatalf("Unexpected number of spans, got %d want 1", len(ctx.stats.spans))
+	}
+	attrs := ctx.stats.spans[0].attributes
+	for _, a := range []string{"/cpu_user_ms", "/cpu_system_ms", "/max_rss_kb", "/block_input_ops", "/block_output_ops"} {
+		if _, ok := attrs[a]; !ok {
+			t.Errorf("span attribute %q missing, got %v", a, attrs)
+		}
+	}
+	if got := attrs["/max_rss_kb"].(int64); got < 10000 {
+		t.Errorf("span attribute /max_rss_kb=%d, want >= 10000", got)
+	}
+	if ctx.stats.usage.userCPU+ctx.stats.usage.systemCPU < 100*time.Millisecond {
+		t.Errorf("stats CPU time got %v, want >= %v", ctx.stats.usage.userCPU+ctx.stats.usage.systemCPU, 100*time.Millisecond)
+	}
+}
@@ -142,5 +142,5 @@ func (ctx *Context) createSpanName(cmd []string) string {
 			trimmed = append(trimmed, t)
 		}
 	}
-	return fmt.Sprintf("Exec %q", strings.Join(trimmed, " "))
+	return fmt.Sprintf("Exec %q", ctx.redact(strings.Join(trimmed, " ")))
 }
@@ -18,6 +18,7 @@ package golang
 import (
 	"path/filepath"
 	"regexp"
+	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/blang/semver"
@@ -34,14 +35,30 @@ var (
 
 	// goModVersionRegexp is used to get correct declaration of Go version from go.mod file.
 	goModVersionRegexp = regexp.MustCompile(`(?m)^\s*go\s+(\d+(\.\d+){1,2})\s*$`)
+
+	// goPrereleaseRegexp is used to split Go pre-release versions such as `1.15beta1` or `1.15rc2`.
+	goPrereleaseRegexp = regexp.MustCompile(`^(\d+\.\d+(?:\.\d+)?)((?:beta|rc)\d+)$`)
 )
 
+// ParseVersion parses a Go release version, e.g. `1.14`, `1.14.2` or `go1.15beta1`, as a semantic version.
+func ParseVersion(v string) (semver.Version, error) {
+	v = strings.TrimPrefix(strings.TrimSpace(v), "go")
+	if m := goPrereleaseRegexp.FindStringSubmatch(v); m != nil {
+		release := m[1]
+		if strings.Count(release, ".") == 1 {
+			release += ".0"
+		}
+		v = release + "-" + m[2]
+	}
+	return semver.ParseTolerant(v)
+}
+
 // SupportsNoGoMod only returns true for Go version 1.11 and 1.13.
 // These are the two GCF-supported versions that don't require a go.mod file.
 func SupportsNoGoMod(ctx *gcp.Context) bool {
 	v := GoVersion(ctx)
 
-	version, err := semver.ParseTolerant(v)
+	version, err := ParseVersion(v)
 	if err != nil {
 		ctx.Exit(1, gcp.InternalErrorf("unable to parse go version string %q: %s", v, err))
 	}
@@ -61,7 +78,7 @@ func SupportsAutoVendor(ctx *gcp.Context) bool {
 		return false
 	}
 
-	version, err := semver.ParseTolerant(v)
+	version, err := ParseVersion(v)
 	if err != nil {
 		ctx.Exit(1, gcp.InternalErrorf("unable to parse go version string %q: %s", v, err))
 	}
@@ -73,7 +90,7 @@ func SupportsAutoVendor(ctx *gcp.Context) bool {
 
 	v = GoVersion(ctx)
 
-	version, err = semver.ParseTolerant(v)
+	version, err = ParseVersion(v)
 	if err != nil {
 		ctx.Exit(1, gcp.InternalErrorf("unable to parse go version string %q: %s", v, err))
 	}
@@ -301,6 +301,10 @@ func TestSupportsNoGoMod(t *testing.T) {
 			goVersion: "go version go1.14 darwin/amd64",
 			want:      false,
 		},
+		{
+			goVersion: "go version go1.15beta1 linux/amd64",
+			want:      false,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -353,6 +357,11 @@ func TestSupportsAutoVendor(t *testing.T) {
 			goMod:     "module dir\ngo 1.14",
 			want:      false,
 		},
+		{
+			goVersion: "go version go1.15rc1 linux/amd64",
+			goMod:     "module dir\ngo 1.14",
+			want:      true,
+		},
 		{
 			goMod: "",
 			want:  false,
@@ -375,3 +384,28 @@ func TestSupportsAutoVendor(t *testing.T) {
 		})
 	}
 }
+
+func TestParseVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+	}{
+		{version: "1.14", want: "1.14.0"},
+		{version: "1.14.2", want: "1.14.2"},
+		{version: "go1.14.2", want: "1.14.2"},
+		{version: "1.15beta1", want: "1.15.0-beta1"},
+		{version: "1.15rc2", want: "1.15.0-rc2"},
+		{version: "1.14.1rc1", want: "1.14.1-rc1"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.version, func(t *testing.T) {
+			got, err := ParseVersion(tc.version)
+			if err != nil {
+				t.Fatalf("ParseVersion(%q) got error: %v", tc.version, err)
+			}
+			if got.String() != tc.want {
+				t.Errorf("ParseVersion(%q) = %q, want %q", tc.version, got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+var (
+	// releaseVersionRegexp matches the version in a JDK's release file, e.g. `JAVA_VERSION="11.0.8"`.
+	releaseVersionRegexp = regexp.MustCompile(`(?m)^JAVA_VERSION="([^"]+)"`)
+
+	// jlinkDefaultModules are added to every jlink runtime; they provide services that jdeps cannot detect.
+	jlinkDefaultModules = []string{"jdk.crypto.ec", "jdk.localedata"}
+)
+
+// JlinkEnabled returns true if GOOGLE_JAVA_JLINK requests a jlink-generated runtime for launch.
+func JlinkEnabled(ctx *gcp.Context) bool {
+	val, present := os.LookupEnv(env.JavaJlink)
+	if !present {
+		return false
+	}
+	enabled, err := strconv.ParseBool(val)
+	if err != nil {
+		ctx.Warnf("%s env var must be parseable to a bool: %q", env.JavaJlink, val)
+		return false
+	}
+	return enabled
+}
+
+// SupportsJlink returns true if the JDK of the feature version provides jlink, which was added in Java 9.
+func SupportsJlink(featureVersion string) bool {
+	v, err := strconv.Atoi(featureVersion)
+	return err == nil && v >= 9
+}
+
+// JDKFeatureVersion returns the feature version of the JDK at javaHome, or an empty string if it has no jlink.
+func JDKFeatureVersion(ctx *gcp.Context, javaHome string) string {
+	if javaHome == "" || !ctx.FileExists(javaHome, "bin", "jlink") || !ctx.FileExists(javaHome, "release") {
+		return ""
+	}
+	m := releaseVersionRegexp.FindSubmatch(ctx.ReadFile(filepath.Join(javaHome, "release")))
+	if m == nil {
+		return ""
+	}
+	v, err := normalizeFeatureVersion(string(m[1]))
+	if err != nil {
+		return ""
+	}
+	return v
+}
+
+// Jlink creates a minimal Java runtime in the launch layer l from the JDK at javaHome, containing the modules that
+// jar and its Class-Path dependencies use. If jdeps cannot determine the modules, all modules of the JDK are included.
+func Jlink(ctx *gcp.Context, l *layers.Layer, javaHome, featureVersion, jar string) {
+	modules := "ALL-MODULE-PATH"
+	if deps, err := moduleDeps(ctx, javaHome, featureVersion, jar); err != nil {
+		ctx.Warnf("Failed to determine the modules used by %s, including all modules: %v", jar, err)
+	} else if deps != "" {
+		modules = strings.Join(append([]string{deps}, jlinkDefaultModules...), ",")
+	}
+
+	ctx.Logf("Creating Java runtime with modules %s", modules)
+	// jlink requires that the output directory does not exist.
+	ctx.RemoveAll(l.Root)
+	ctx.Exec([]string{
+		filepath.Join(javaHome, "bin", "jlink"),
+		"--module-path", filepath.Join(javaHome, "jmods"),
+		"--add-modules", modules,
+		"--strip-debug", "--no-header-files", "--no-man-pages", "--compress=2",
+		"--output", l.Root,
+	})
+	ctx.OverrideLaunchEnv(l, "JAVA_HOME", l.Root)
+	ctx.WriteMetadata(l, nil, layers.Launch)
+}
+
+// moduleDeps returns the comma-separated JDK modules that jar and the jars on its Class-Path use, as reported by jdeps.
+func moduleDeps(ctx *gcp.Context, javaHome, featureVersion, jar string) (string, error) {
+	classPath, nested, err := jarClassPath(jar)
+	if err != nil {
+		return "", err
+	}
+	if nested {
+		return "", fmt.Errorf("dependencies nested in the jar, e.g. in BOOT-INF/lib, cannot be analyzed")
+	}
+	cmd := []string{filepath.Join(javaHome, "bin", "jdeps"), "--print-module-deps", "--ignore-missing-deps", "--recursive", "--multi-release", featureVersion, "-q"}
+	if len(classPath) > 0 {
+		cmd = append(cmd, "--class-path", strings.Join(classPath, string(os.PathListSeparator)))
+	}
+	result, err := ctx.ExecWithErr(append(cmd, jar))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(result.Stdout), nil
+}
+
+// jarClassPath returns the existing jars on the Class-Path of jar's manifest, and whether jar nests its dependencies,
+// as Spring Boot (BOOT-INF/lib) and war (WEB-INF/lib) archives do.
+func jarClassPath(jar string) ([]string, bool, error) {
+	r, err := zip.OpenReader(jar)
+	if err != nil {
+		return nil, false, fmt.Errorf("unzipping jar %s: %v", jar, err)
+	}
+	defer r.Close()
+	var manifest []byte
+	for _, f := range r.File {
+		if strings.HasPrefix(f.Name, "BOOT-INF/lib/") || strings.HasPrefix(f.Name, "WEB-INF/lib/") {
+			return nil, true, nil
+		}
+		if f.Name != "META-INF/MANIFEST.MF" {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return nil, false, fmt.Errorf("opening file %s in jar %s: %v", f.Name, jar, err)
+		}
+		manifest, err = ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, false, fmt.Errorf("reading file %s in jar %s: %v", f.Name, jar, err)
+		}
+	}
+
+	var classPath []string
+	for _, entry := range strings.Fields(manifestAttribute(string(manifest), "Class-Path")) {
+		path := entry
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(jar), entry)
+		}
+		if _, err := os.Stat(path); err == nil {
+			classPath = append(classPath, path)
+		}
+	}
+	return classPath, false, nil
+}
+
+// manifestAttribute returns the value of the main section attribute name, joining continuation lines.
+func manifestAttribute(manifest, name string) string {
+	var value string
+	found := false
+	for _, line := range strings.Split(strings.ReplaceAll(manifest, "\r\n", "\n"), "\n") {
+		switch {
+		case found && strings.HasPrefix(line, " "):
+			// Lines are wrapped at 72 bytes, and continued after a single space.
+			value += line[1:]
+		case found || line == "":
+			// A blank line ends the main section.
+			return value
+		case strings.HasPrefix(line, name+": "):
+			value = strings.TrimPrefix(line, name+": ")
+			found = true
+		}
+	}
+	return value
+}
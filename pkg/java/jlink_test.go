@@ -0,0 +1,247 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"archive/zip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestJlinkEnabled(t *testing.T) {
+	testCases := []struct {
+		value string
+		set   bool
+		want  bool
+	}{
+		{set: false, want: false},
+		{value: "true", set: true, want: true},
+		{value: "1", set: true, want: true},
+		{value: "false", set: true, want: false},
+		{value: "invalid", set: true, want: false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.value, func(t *testing.T) {
+			if tc.set {
+				if err := os.Setenv(env.JavaJlink, tc.value); err != nil {
+					t.Fatalf("Failed to set env: %v", err)
+				}
+				defer func() {
+					if err := os.Unsetenv(env.JavaJlink); err != nil {
+						t.Fatalf("Failed to unset env: %v", err)
+					}
+				}()
+			}
+			ctx := gcp.NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+
+			if got := JlinkEnabled(ctx); got != tc.want {
+				t.Errorf("JlinkEnabled() with %s=%q = %t, want %t", env.JavaJlink, tc.value, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSupportsJlink(t *testing.T) {
+	testCases := []struct {
+		featureVersion string
+		want           bool
+	}{
+		{featureVersion: "8", want: false},
+		{featureVersion: "9", want: true},
+		{featureVersion: "11", want: true},
+		{featureVersion: "invalid", want: false},
+	}
+	for _, tc := range testCases {
+		if got := SupportsJlink(tc.featureVersion); got != tc.want {
+			t.Errorf("SupportsJlink(%q) = %t, want %t", tc.featureVersion, got, tc.want)
+		}
+	}
+}
+
+func TestJDKFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		name    string
+		release string
+		jlink   bool
+		want    string
+	}{
+		{
+			name:    "jdk 11",
+			release: "IMPLEMENTOR=\"AdoptOpenJDK\"\nJAVA_VERSION=\"11.0.8\"\n",
+			jlink:   true,
+			want:    "11",
+		},
+		{
+			name:    "no jlink",
+			release: "JAVA_VERSION=\"1.8.0_265\"\n",
+			want:    "",
+		},
+		{
+			name:    "no version",
+			release: "IMPLEMENTOR=\"AdoptOpenJDK\"\n",
+			jlink:   true,
+			want:    "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			javaHome, err := ioutil.TempDir("", "jdk-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(javaHome)
+			if err := ioutil.WriteFile(filepath.Join(javaHome, "release"), []byte(tc.release), 0644); err != nil {
+				t.Fatalf("writing release: %v", err)
+			}
+			if tc.jlink {
+				if err := os.Mkdir(filepath.Join(javaHome, "bin"), 0755); err != nil {
+					t.Fatalf("creating bin: %v", err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(javaHome, "bin", "jlink"), nil, 0755); err != nil {
+					t.Fatalf("writing jlink: %v", err)
+				}
+			}
+			ctx := gcp.NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+
+			if got := JDKFeatureVersion(ctx, javaHome); got != tc.want {
+				t.Errorf("JDKFeatureVersion() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestManifestAttribute(t *testing.T) {
+	testCases := []struct {
+		name     string
+		manifest string
+		want     string
+	}{
+		{
+			name:     "single line",
+			manifest: "Manifest-Version: 1.0\r\nClass-Path: lib/a.jar lib/b.jar\r\nMain-Class: Main\r\n",
+			want:     "lib/a.jar lib/b.jar",
+		},
+		{
+			name:     "continuation lines",
+			manifest: "Class-Path: lib/a.jar li\n b/b.jar\nMain-Class: Main\n",
+			want:     "lib/a.jar lib/b.jar",
+		},
+		{
+			name:     "only main section",
+			manifest: "Main-Class: Main\n\nName: foo/\nClass-Path: lib/a.jar\n",
+			want:     "",
+		},
+		{
+			name:     "missing",
+			manifest: "Main-Class: Main\n",
+			want:     "",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := manifestAttribute(tc.manifest, "Class-Path"); got != tc.want {
+				t.Errorf("manifestAttribute() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestJarClassPath(t *testing.T) {
+	testCases := []struct {
+		name       string
+		files      map[string]string
+		libs       []string
+		want       []string
+		wantNested bool
+	}{
+		{
+			name:  "existing class path entries",
+			files: map[string]string{"META-INF/MANIFEST.MF": "Main-Class: Main\nClass-Path: lib/a.jar lib/missing.jar\n"},
+			libs:  []string{"lib/a.jar"},
+			want:  []string{"lib/a.jar"},
+		},
+		{
+			name:  "no class path",
+			files: map[string]string{"META-INF/MANIFEST.MF": "Main-Class: Main\n"},
+		},
+		{
+			name: "spring boot",
+			files: map[string]string{
+				"META-INF/MANIFEST.MF":   "Main-Class: org.springframework.boot.loader.JarLauncher\n",
+				"BOOT-INF/lib/dep-1.jar": "",
+			},
+			wantNested: true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "jar-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			jar := filepath.Join(dir, "app.jar")
+			writeJar(t, jar, tc.files)
+			var want []string
+			for _, l := range tc.libs {
+				writeJar(t, filepath.Join(dir, l), nil)
+			}
+			for _, w := range tc.want {
+				want = append(want, filepath.Join(dir, w))
+			}
+
+			got, nested, err := jarClassPath(jar)
+			if err != nil {
+				t.Fatalf("jarClassPath() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, want) || nested != tc.wantNested {
+				t.Errorf("jarClassPath() = %v, %t, want %v, %t", got, nested, want, tc.wantNested)
+			}
+		})
+	}
+}
+
+// writeJar writes a jar at path containing files.
+func writeJar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatalf("creating %s: %v", filepath.Dir(path), err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatalf("creating %s: %v", path, err)
+	}
+	defer f.Close()
+	w := zip.NewWriter(f)
+	for name, content := range files {
+		fw, err := w.Create(name)
+		if err != nil {
+			t.Fatalf("adding %s to %s: %v", name, path, err)
+		}
+		if _, err := fw.Write([]byte(content)); err != nil {
+			t.Fatalf("writing %s to %s: %v", name, path, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("closing %s: %v", path, err)
+	}
+}
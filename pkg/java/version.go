@@ -0,0 +1,181 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+var (
+	// featureVersionRegexp matches the feature version in versions such as `11`, `11.0.8`, `1.8.0_292` or `openjdk64-11.0.2`.
+	featureVersionRegexp = regexp.MustCompile(`(?:^|[^\d.])(?:1\.(\d+)|(\d+))(?:\.[\d._]*)?$`)
+
+	// gradleToolchainRegexp matches a toolchain block version, e.g. `languageVersion = JavaLanguageVersion.of(17)`.
+	gradleToolchainRegexp = regexp.MustCompile(`languageVersion(?:\s*=\s*|\.set\(\s*)JavaLanguageVersion\.of\(\s*['"]?(\d+)`)
+
+	// gradleSourceCompatibilityRegexp matches e.g. `sourceCompatibility = '1.8'` or `sourceCompatibility = JavaVersion.VERSION_11`.
+	gradleSourceCompatibilityRegexp = regexp.MustCompile(`(?m)^\s*(?:java\.)?sourceCompatibility\s*=\s*(?:JavaVersion\.VERSION_([\d_]+)|['"]?([\d.]+)['"]?)`)
+
+	// pomPropertyRegexp matches a property reference, e.g. `${java.version}`.
+	pomPropertyRegexp = regexp.MustCompile(`^\$\{(.+)\}$`)
+)
+
+// FeatureVersion infers the Java feature version, e.g. `8` or `17`, from the application's files.
+// It returns the version along with the file that specified it, or empty strings if no file does.
+// The files are checked in order: .java-version, system.properties, pom.xml, build.gradle and build.gradle.kts.
+func FeatureVersion(ctx *gcp.Context) (string, string, error) {
+	sources := []struct {
+		file  string
+		parse func([]byte) (string, error)
+	}{
+		{file: ".java-version", parse: parseJavaVersionFile},
+		{file: "system.properties", parse: parseSystemProperties},
+		{file: "pom.xml", parse: parsePom},
+		{file: "build.gradle", parse: parseGradle},
+		{file: "build.gradle.kts", parse: parseGradle},
+	}
+	for _, s := range sources {
+		path := filepath.Join(ctx.ApplicationRoot(), s.file)
+		if !ctx.FileExists(path) {
+			continue
+		}
+		v, err := s.parse(ctx.ReadFile(path))
+		if err != nil {
+			return "", "", gcp.UserErrorf("parsing Java version from %s: %v", s.file, err)
+		}
+		if v != "" {
+			return v, s.file, nil
+		}
+	}
+	return "", "", nil
+}
+
+// normalizeFeatureVersion returns the feature version of a Java version, e.g. `8` for `1.8` and `11` for `11.0.8`.
+func normalizeFeatureVersion(v string) (string, error) {
+	v = strings.Trim(strings.TrimSpace(v), `"'`)
+	m := featureVersionRegexp.FindStringSubmatch(v)
+	if m == nil {
+		return "", fmt.Errorf("invalid Java version %q", v)
+	}
+	feature := m[1] + m[2]
+	if _, err := strconv.Atoi(feature); err != nil {
+		return "", fmt.Errorf("invalid Java version %q", v)
+	}
+	return feature, nil
+}
+
+// parseJavaVersionFile parses a .java-version file, as used by jenv, which holds a single version.
+func parseJavaVersionFile(b []byte) (string, error) {
+	v := strings.TrimSpace(string(b))
+	if v == "" {
+		return "", fmt.Errorf("file does not specify a version")
+	}
+	return normalizeFeatureVersion(v)
+}
+
+// parseSystemProperties reads `java.runtime.version` from a system.properties file.
+func parseSystemProperties(b []byte) (string, error) {
+	s := bufio.NewScanner(bytes.NewReader(b))
+	for s.Scan() {
+		line := strings.TrimSpace(s.Text())
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "!") {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 || strings.TrimSpace(kv[0]) != "java.runtime.version" {
+			continue
+		}
+		return normalizeFeatureVersion(kv[1])
+	}
+	return "", s.Err()
+}
+
+// pomProject holds the parts of a pom.xml that specify the Java version.
+type pomProject struct {
+	Properties struct {
+		Entries []struct {
+			XMLName xml.Name
+			Value   string `xml:",chardata"`
+		} `xml:",any"`
+	} `xml:"properties"`
+	Plugins []struct {
+		ArtifactID    string `xml:"artifactId"`
+		Configuration struct {
+			Release string `xml:"release"`
+			Source  string `xml:"source"`
+		} `xml:"configuration"`
+	} `xml:"build>plugins>plugin"`
+}
+
+// parsePom reads the Java version from the maven-compiler-plugin configuration or the `maven.compiler.release`,
+// `maven.compiler.source` or `java.version` (used by Spring Boot) properties of a pom.xml, in that order.
+func parsePom(b []byte) (string, error) {
+	var p pomProject
+	if err := xml.Unmarshal(b, &p); err != nil {
+		return "", err
+	}
+	props := map[string]string{}
+	for _, e := range p.Properties.Entries {
+		props[e.XMLName.Local] = strings.TrimSpace(e.Value)
+	}
+	// resolve expands a reference to another property, e.g. `${java.version}`.
+	resolve := func(v string) string {
+		for i := 0; i < 10; i++ {
+			m := pomPropertyRegexp.FindStringSubmatch(v)
+			if m == nil {
+				break
+			}
+			v = props[m[1]]
+		}
+		return v
+	}
+
+	var candidates []string
+	for _, plugin := range p.Plugins {
+		if plugin.ArtifactID == "maven-compiler-plugin" {
+			candidates = append(candidates, plugin.Configuration.Release, plugin.Configuration.Source)
+		}
+	}
+	candidates = append(candidates, props["maven.compiler.release"], props["maven.compiler.source"], props["java.version"])
+	for _, c := range candidates {
+		if v := resolve(strings.TrimSpace(c)); v != "" {
+			return normalizeFeatureVersion(v)
+		}
+	}
+	return "", nil
+}
+
+// parseGradle reads the Java version from a toolchain block or `sourceCompatibility` of a Gradle build script.
+func parseGradle(b []byte) (string, error) {
+	if m := gradleToolchainRegexp.FindSubmatch(b); m != nil {
+		return normalizeFeatureVersion(string(m[1]))
+	}
+	if m := gradleSourceCompatibilityRegexp.FindSubmatch(b); m != nil {
+		if len(m[1]) > 0 {
+			return normalizeFeatureVersion(strings.Replace(string(m[1]), "_", ".", -1))
+		}
+		return normalizeFeatureVersion(string(m[2]))
+	}
+	return "", nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package java
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		name     string
+		files    map[string]string
+		want     string
+		wantFile string
+	}{
+		{
+			name:  "no version",
+			files: map[string]string{"Main.java": ""},
+		},
+		{
+			name:     ".java-version",
+			files:    map[string]string{".java-version": "1.8\n"},
+			want:     "8",
+			wantFile: ".java-version",
+		},
+		{
+			name:     "system.properties",
+			files:    map[string]string{"system.properties": "# Heroku\njava.runtime.version=11.0.8\n"},
+			want:     "11",
+			wantFile: "system.properties",
+		},
+		{
+			name: "pom.xml maven.compiler.release",
+			files: map[string]string{"pom.xml": `<project>
+  <properties>
+    <maven.compiler.source>1.8</maven.compiler.source>
+    <maven.compiler.release>17</maven.compiler.release>
+  </properties>
+</project>`},
+			want:     "17",
+			wantFile: "pom.xml",
+		},
+		{
+			name: "pom.xml spring boot java.version",
+			files: map[string]string{"pom.xml": `<project>
+  <parent><artifactId>spring-boot-starter-parent</artifactId></parent>
+  <properties><java.version>1.8</java.version></properties>
+</project>`},
+			want:     "8",
+			wantFile: "pom.xml",
+		},
+		{
+			name: "pom.xml compiler plugin",
+			files: map[string]string{"pom.xml": `<project>
+  <properties><jdk.version>14</jdk.version></properties>
+  <build>
+    <plugins>
+      <plugin>
+        <artifactId>maven-compiler-plugin</artifactId>
+        <configuration><release>${jdk.version}</release></configuration>
+      </plugin>
+    </plugins>
+  </build>
+</project>`},
+			want:     "14",
+			wantFile: "pom.xml",
+		},
+		{
+			name:  "pom.xml without version",
+			files: map[string]string{"pom.xml": `<project><artifactId>app</artifactId></project>`},
+		},
+		{
+			name:     "gradle sourceCompatibility",
+			files:    map[string]string{"build.gradle": "plugins { id 'java' }\nsourceCompatibility = '1.8'\n"},
+			want:     "8",
+			wantFile: "build.gradle",
+		},
+		{
+			name:     "gradle JavaVersion",
+			files:    map[string]string{"build.gradle": "java {\n  sourceCompatibility = JavaVersion.VERSION_11\n}\n"},
+			want:     "11",
+			wantFile: "build.gradle",
+		},
+		{
+			name:     "gradle kotlin toolchain",
+			files:    map[string]string{"build.gradle.kts": "java {\n  toolchain {\n    languageVersion.set(JavaLanguageVersion.of(17))\n  }\n}\n"},
+			want:     "17",
+			wantFile: "build.gradle.kts",
+		},
+		{
+			name: "precedence",
+			files: map[string]string{
+				".java-version": "11",
+				"pom.xml":       `<project><properties><java.version>8</java.version></properties></project>`,
+			},
+			want:     "11",
+			wantFile: ".java-version",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "app-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for f, c := range tc.files {
+				if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, gotFile, err := FeatureVersion(ctx)
+			if err != nil {
+				t.Fatalf("FeatureVersion() got error: %v", err)
+			}
+			if got != tc.want || gotFile != tc.wantFile {
+				t.Errorf("FeatureVersion() = %q, %q, want %q, %q", got, gotFile, tc.want, tc.wantFile)
+			}
+		})
+	}
+}
+
+func TestNormalizeFeatureVersion(t *testing.T) {
+	testCases := []struct {
+		version string
+		want    string
+	}{
+		{version: "8", want: "8"},
+		{version: "1.8", want: "8"},
+		{version: "1.8.0_292", want: "8"},
+		{version: "11", want: "11"},
+		{version: "11.0.8", want: "11"},
+		{version: `"17"`, want: "17"},
+		{version: "openjdk64-11.0.2", want: "11"},
+	}
+	for _, tc := range testCases {
+		got, err := normalizeFeatureVersion(tc.version)
+		if err != nil {
+			t.Errorf("normalizeFeatureVersion(%q) got error: %v", tc.version, err)
+		} else if got != tc.want {
+			t.Errorf("normalizeFeatureVersion(%q) = %q, want %q", tc.version, got, tc.want)
+		}
+	}
+
+	for _, v := range []string{"", "latest", "java"} {
+		if got, err := normalizeFeatureVersion(v); err == nil {
+			t.Errorf("normalizeFeatureVersion(%q) = %q, want error", v, got)
+		}
+	}
+}
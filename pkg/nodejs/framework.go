@@ -0,0 +1,174 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+// Framework describes how to build, cache and start an application built on a Node.js web framework.
+type Framework struct {
+	// Name is the display name of the framework.
+	Name string
+	// Build is the framework command that builds the application, run when package.json has no build scripts.
+	Build []string
+	// CacheDir is the incremental build directory of the framework, relative to the application root.
+	CacheDir string
+	// Start is the command that serves the application on $PORT.
+	Start []string
+	// StartBinary is true if Start runs a package binary rather than node.
+	StartBinary bool
+	// layer is the name of the cache layer holding CacheDir.
+	layer string
+}
+
+var (
+	nextJS = Framework{
+		Name:        "Next.js",
+		Build:       []string{"next", "build"},
+		CacheDir:    ".next/cache",
+		Start:       []string{"next", "start", "--port", "${PORT:-8080}"},
+		StartBinary: true,
+		layer:       "next_cache",
+	}
+	nuxt2 = Framework{
+		Name:        "Nuxt",
+		Build:       []string{"nuxt", "build"},
+		CacheDir:    "node_modules/.cache",
+		Start:       []string{"nuxt", "start", "--hostname", "0.0.0.0", "--port", "${PORT:-8080}"},
+		StartBinary: true,
+		layer:       "nuxt_cache",
+	}
+	// Nuxt 3 builds a standalone Nitro server, which listens on $PORT on all interfaces.
+	nuxt3 = Framework{
+		Name:     "Nuxt",
+		Build:    []string{"nuxi", "build"},
+		CacheDir: "node_modules/.cache",
+		Start:    []string{"node", ".output/server/index.mjs"},
+		layer:    "nuxt_cache",
+	}
+)
+
+// DetectFramework returns the web framework the application depends on, or nil if none is supported.
+func DetectFramework(p *PackageJSON) *Framework {
+	if p == nil {
+		return nil
+	}
+	dep := func(name string) (string, bool) {
+		if v, ok := p.Dependencies[name]; ok {
+			return v, true
+		}
+		v, ok := p.DevDependencies[name]
+		return v, ok
+	}
+	if _, ok := dep("next"); ok {
+		return &nextJS
+	}
+	if _, ok := dep("nuxt3"); ok {
+		return &nuxt3
+	}
+	if v, ok := dep("nuxt"); ok {
+		if majorVersion(v) >= 3 {
+			return &nuxt3
+		}
+		return &nuxt2
+	}
+	return nil
+}
+
+// majorVersion returns the major version of a dependency version range such as ^3.0.0, or -1 if unknown.
+func majorVersion(r string) int {
+	r = strings.TrimLeft(strings.TrimSpace(r), "^~>=v ")
+	if i := strings.IndexAny(r, ".-"); i >= 0 {
+		r = r[:i]
+	}
+	major, err := strconv.Atoi(r)
+	if err != nil {
+		return -1
+	}
+	return major
+}
+
+// StartCommand returns the web process command that starts the application with the framework, where execBin is the
+// package manager command that runs package binaries.
+func (f *Framework) StartCommand(execBin []string) []string {
+	cmd := f.Start
+	if f.StartBinary {
+		cmd = append(append([]string{}, execBin...), cmd...)
+	}
+	return []string{"/bin/bash", "-c", "exec " + strings.Join(cmd, " ")}
+}
+
+// RestoreBuildCache copies the incremental build directory of the framework from its cache layer into the
+// application. The returned function saves the directory back to the layer and must be called after the build.
+func (f *Framework) RestoreBuildCache(ctx *gcp.Context) func() {
+	l := ctx.Layer(f.layer)
+	dir := filepath.Join(ctx.ApplicationRoot(), f.CacheDir)
+	if len(ctx.Glob(filepath.Join(l.Root, "*"))) > 0 {
+		ctx.CacheHit(f.layer)
+		ctx.MkdirAll(dir, 0755)
+		ctx.Exec([]string{"cp", "--archive", l.Root + "/.", dir})
+	} else {
+		ctx.CacheMiss(f.layer)
+	}
+	return func() {
+		ctx.ClearLayer(l)
+		if ctx.FileExists(dir) {
+			ctx.Exec([]string{"cp", "--archive", dir + "/.", l.Root})
+		}
+		ctx.WriteMetadata(l, nil, layers.Cache)
+	}
+}
+
+// BuildFramework runs the build scripts of package.json, or the framework build if there are none, with the
+// incremental build directory of the framework cached between builds. runScript and execBin are the package manager
+// commands that run scripts and package binaries.
+func BuildFramework(ctx *gcp.Context, f *Framework, p *PackageJSON, runScript, execBin []string) {
+	ctx.Logf("Building %s application.", f.Name)
+	save := f.RestoreBuildCache(ctx)
+	scripts := p.BuildScripts()
+	for _, script := range scripts {
+		ctx.ExecUser(append(append([]string{}, runScript...), script))
+	}
+	if len(scripts) == 0 {
+		ctx.ExecUser(append(append([]string{}, execBin...), f.Build...))
+	}
+	save()
+}
+
+// FrameworkStartCommand returns the web process command that starts the application with its framework, or nil if
+// package.json has a start script or the application does not use a supported framework. execBin is the package
+// manager command that runs package binaries.
+func FrameworkStartCommand(ctx *gcp.Context, execBin []string) ([]string, error) {
+	p, err := ReadPackageJSON(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, fmt.Errorf("reading package.json: %w", err)
+	}
+	if p.Scripts["start"] != "" {
+		return nil, nil
+	}
+	f := DetectFramework(p)
+	if f == nil {
+		return nil, nil
+	}
+	ctx.Logf("No start script found in package.json, starting %s application with %q.", f.Name, strings.Join(f.Start, " "))
+	return f.StartCommand(execBin), nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestDetectFramework(t *testing.T) {
+	testCases := []struct {
+		name string
+		pjs  *PackageJSON
+		want *Framework
+	}{
+		{
+			name: "no framework",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"express": "^4.17.1"}},
+		},
+		{
+			name: "next",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"next": "10.0.5", "react": "17.0.1"}},
+			want: &nextJS,
+		},
+		{
+			name: "nuxt 2",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"nuxt": "^2.14.12"}},
+			want: &nuxt2,
+		},
+		{
+			name: "nuxt 3",
+			pjs:  &PackageJSON{DevDependencies: map[string]string{"nuxt": "^3.0.0-rc.1"}},
+			want: &nuxt3,
+		},
+		{
+			name: "nuxt3 package",
+			pjs:  &PackageJSON{DevDependencies: map[string]string{"nuxt3": "latest"}},
+			want: &nuxt3,
+		},
+		{
+			name: "nuxt tag",
+			pjs:  &PackageJSON{Dependencies: map[string]string{"nuxt": "latest"}},
+			want: &nuxt2,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := DetectFramework(tc.pjs); got != tc.want {
+				t.Errorf("DetectFramework() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFrameworkStartCommand(t *testing.T) {
+	testCases := []struct {
+		name        string
+		packageJSON string
+		want        []string
+	}{
+		{
+			name:        "next",
+			packageJSON: `{"dependencies": {"next": "^10.0.0"}}`,
+			want:        []string{"/bin/bash", "-c", "exec npx --no-install next start --port ${PORT:-8080}"},
+		},
+		{
+			name:        "nuxt 3",
+			packageJSON: `{"dependencies": {"nuxt": "^3.0.0"}}`,
+			want:        []string{"/bin/bash", "-c", "exec node .output/server/index.mjs"},
+		},
+		{
+			name:        "start script",
+			packageJSON: `{"dependencies": {"next": "^10.0.0"}, "scripts": {"start": "next start"}}`,
+		},
+		{
+			name:        "no framework",
+			packageJSON: `{"dependencies": {"express": "^4.17.1"}}`,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "framework-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			if err := ioutil.WriteFile(filepath.Join(dir, "package.json"), []byte(tc.packageJSON), 0644); err != nil {
+				t.Fatalf("writing package.json: %v", err)
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			got, err := FrameworkStartCommand(ctx, []string{"npx", "--no-install"})
+			if err != nil {
+				t.Fatalf("FrameworkStartCommand() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("FrameworkStartCommand() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+const (
+	nodeGypLayer = "node-gyp"
+	bindingGyp   = "binding.gyp"
+)
+
+// nodeGypMetadata represents metadata stored for the node-gyp headers layer.
+type nodeGypMetadata struct {
+	NodeVersion string `toml:"node_version"`
+}
+
+// NodeABI returns the ABI version of native addons built for the installed version of Node.js.
+func NodeABI(ctx *gcp.Context) string {
+	result := ctx.Exec([]string{"node", "-p", "process.versions.modules"})
+	return strings.TrimSpace(result.Stdout)
+}
+
+// StackID returns the ID of the stack the application is built on. Native addons link against its libraries.
+func StackID() string {
+	return os.Getenv("CNB_STACK_ID")
+}
+
+// CacheNodeGypHeaders keeps the Node.js headers downloaded by node-gyp to compile native addons in a cache layer, so
+// that rebuilding them does not download the headers again.
+func CacheNodeGypHeaders(ctx *gcp.Context) {
+	l := ctx.Layer(nodeGypLayer)
+	nodeVersion := strings.TrimSpace(NodeVersion(ctx))
+
+	var meta nodeGypMetadata
+	ctx.ReadMetadata(l, &meta)
+	if meta.NodeVersion != nodeVersion {
+		// Only keep the headers of the installed version.
+		ctx.ClearLayer(l)
+	}
+	meta.NodeVersion = nodeVersion
+	ctx.WriteMetadata(l, meta, layers.Cache)
+	// node-gyp reads npm configuration from the environment, whichever package manager runs it.
+	ctx.Setenv("npm_config_devdir", l.Root)
+}
+
+// NativeAddons returns the directories, relative to dir, of the installed packages with a binding.gyp, which are
+// compiled by node-gyp on installation.
+func NativeAddons(dir string) ([]string, error) {
+	var addons []string
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != bindingGyp {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, filepath.Dir(path))
+		if err != nil {
+			return err
+		}
+		addons = append(addons, rel)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	return addons, err
+}
@@ -0,0 +1,52 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNativeAddons(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"node_modules/express/package.json":                      "{}",
+		"node_modules/bcrypt/binding.gyp":                        "",
+		"node_modules/@scope/sharp/binding.gyp":                  "",
+		"node_modules/app/node_modules/fsevents/binding.gyp":     "",
+		"node_modules/app/node_modules/fsevents/src/binding.cpp": "",
+	})
+	defer os.RemoveAll(dir)
+
+	got, err := NativeAddons(filepath.Join(dir, "node_modules"))
+	if err != nil {
+		t.Fatalf("NativeAddons() got error: %v", err)
+	}
+	want := []string{"@scope/sharp", "app/node_modules/fsevents", "bcrypt"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("NativeAddons() = %v, want %v", got, want)
+	}
+}
+
+func TestNativeAddonsMissingDir(t *testing.T) {
+	got, err := NativeAddons(filepath.Join(os.TempDir(), "does-not-exist", "node_modules"))
+	if err != nil {
+		t.Fatalf("NativeAddons() got error: %v", err)
+	}
+	if got != nil {
+		t.Errorf("NativeAddons() = %v, want nil", got)
+	}
+}
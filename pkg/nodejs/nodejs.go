@@ -21,8 +21,10 @@ import (
 	"io/ioutil"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
 	"github.com/buildpack/libbuildpack/layers"
 )
@@ -32,31 +34,72 @@ const (
 	EnvDevelopment = "development"
 	// EnvProduction represents a NODE_ENV production value.
 	EnvProduction = "production"
+
+	// ScriptGCPBuild is the name of the package.json script that builds the application for Google Cloud.
+	ScriptGCPBuild = "gcp-build"
+	// ScriptBuild is the name of the conventional package.json build script.
+	ScriptBuild = "build"
 )
 
 type packageEnginesJSON struct {
 	Node string `json:"node"`
 }
 
-type packageScriptsJSON struct {
-	Start    string `json:"start"`
-	GCPBuild string `json:"gcp-build"`
-}
+// packageScriptsJSON maps script names to commands.
+type packageScriptsJSON map[string]string
 
 // PackageJSON represents the contents of a package.json file.
 type PackageJSON struct {
+	Name            string             `json:"name"`
 	Main            string             `json:"main"`
+	PackageManager  string             `json:"packageManager"`
+	Type            string             `json:"type"`
 	Version         string             `json:"version"`
 	Engines         packageEnginesJSON `json:"engines"`
 	Scripts         packageScriptsJSON `json:"scripts"`
 	Dependencies    map[string]string  `json:"dependencies"`
 	DevDependencies map[string]string  `json:"devDependencies"`
+	Workspaces      workspacesJSON     `json:"workspaces"`
+}
+
+// PackageManagerVersion returns the version of the named package manager in the `packageManager` field, e.g.
+// `5.18.10` for `pnpm@5.18.10+sha256.abc`, or an empty string if the field specifies another package manager.
+func (p *PackageJSON) PackageManagerVersion(name string) string {
+	parts := strings.SplitN(strings.TrimSpace(p.PackageManager), "@", 2)
+	if len(parts) != 2 || parts[0] != name {
+		return ""
+	}
+	// Strip the optional hash of the package manager archive.
+	return strings.SplitN(parts[1], "+", 2)[0]
+}
+
+// BuildScripts returns the scripts defined in package.json to run during the build: those listed in
+// GOOGLE_NODE_RUN_SCRIPTS if it is set, otherwise `gcp-build`, or `build` if `gcp-build` is absent.
+func (p *PackageJSON) BuildScripts() []string {
+	var scripts []string
+	if v, ok := os.LookupEnv(env.NodeRunScripts); ok {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" && p.Scripts[s] != "" {
+				scripts = append(scripts, s)
+			}
+		}
+		return scripts
+	}
+	for _, s := range []string{ScriptGCPBuild, ScriptBuild} {
+		if p.Scripts[s] != "" {
+			return []string{s}
+		}
+	}
+	return nil
 }
 
 // Metadata represents metadata stored for a dependencies layer.
 type Metadata struct {
-	NodeVersion    string `toml:"node_version"`
-	DependencyHash string `toml:"dependency_hash"`
+	NodeVersion    string   `toml:"node_version"`
+	NodeABI        string   `toml:"node_abi"`
+	Stack          string   `toml:"stack"`
+	DependencyHash string   `toml:"dependency_hash"`
+	NativeAddons   []string `toml:"native_addons"`
 }
 
 // ReadPackageJSON returns deserialized package.json from the given dir. Empty dir uses the current working directory.
@@ -88,10 +131,13 @@ func NodeEnv() string {
 	return nodeEnv
 }
 
-// CheckCache checks whether cached dependencies exist and match.
+// CheckCache checks whether cached dependencies exist and match. Native addons are built for the Node.js ABI and
+// against the libraries of the stack, so both are part of the cache key.
 func CheckCache(ctx *gcp.Context, l *layers.Layer, opts ...cache.Option) (bool, *Metadata, error) {
 	currentNodeVersion := NodeVersion(ctx)
-	opts = append(opts, cache.WithStrings(currentNodeVersion))
+	currentNodeABI := NodeABI(ctx)
+	currentStack := StackID()
+	opts = append(opts, cache.WithStrings(currentNodeVersion, currentNodeABI, currentStack))
 	currentDependencyHash, err := cache.Hash(ctx, opts...)
 	if err != nil {
 		return false, nil, fmt.Errorf("computing dependency hash: %v", err)
@@ -110,11 +156,29 @@ func CheckCache(ctx *gcp.Context, l *layers.Layer, opts ...cache.Option) (bool,
 
 	if meta.DependencyHash == "" {
 		ctx.Debugf("No metadata found from a previous build, skipping cache.")
+	} else if len(meta.NativeAddons) > 0 && (meta.NodeABI != currentNodeABI || meta.Stack != currentStack) {
+		ctx.Logf("Node.js ABI or stack changed, rebuilding native addons: %s", strings.Join(meta.NativeAddons, ", "))
 	}
 	ctx.Logf("Installing application dependencies.")
 	// Update the layer metadata.
 	meta.DependencyHash = currentDependencyHash
 	meta.NodeVersion = currentNodeVersion
+	meta.NodeABI = currentNodeABI
+	meta.Stack = currentStack
+	meta.NativeAddons = nil
 
 	return false, &meta, nil
 }
+
+// RecordNativeAddons stores the native addons installed in node_modules in the metadata of a dependencies layer.
+func RecordNativeAddons(ctx *gcp.Context, meta *Metadata) error {
+	addons, err := NativeAddons(filepath.Join(ctx.ApplicationRoot(), "node_modules"))
+	if err != nil {
+		return fmt.Errorf("finding native addons: %w", err)
+	}
+	if len(addons) > 0 {
+		ctx.Logf("Installed native addons: %s", strings.Join(addons, ", "))
+	}
+	meta.NativeAddons = addons
+	return nil
+}
@@ -21,6 +21,8 @@ import (
 	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
 )
 
 func TestReadPackageJSON(t *testing.T) {
@@ -57,7 +59,7 @@ func TestReadPackageJSON(t *testing.T) {
 			Node: "my-node",
 		},
 		Scripts: packageScriptsJSON{
-			Start: "my-start",
+			"start": "my-start",
 		},
 		Dependencies: map[string]string{
 			"a": "1.0",
@@ -75,3 +77,84 @@ func TestReadPackageJSON(t *testing.T) {
 		t.Errorf("ReadPackageJSON\ngot %#v\nwant %#v", *got, want)
 	}
 }
+
+func TestPackageManagerVersion(t *testing.T) {
+	testCases := []struct {
+		packageManager string
+		name           string
+		want           string
+	}{
+		{packageManager: "pnpm@5.18.10", name: "pnpm", want: "5.18.10"},
+		{packageManager: "pnpm@6.0.0+sha256.0123abcd", name: "pnpm", want: "6.0.0"},
+		{packageManager: "yarn@2.4.0", name: "pnpm", want: ""},
+		{packageManager: "pnpm", name: "pnpm", want: ""},
+		{packageManager: "", name: "pnpm", want: ""},
+	}
+	for _, tc := range testCases {
+		p := PackageJSON{PackageManager: tc.packageManager}
+		if got := p.PackageManagerVersion(tc.name); got != tc.want {
+			t.Errorf("PackageManagerVersion(%q) with packageManager %q = %q, want %q", tc.name, tc.packageManager, got, tc.want)
+		}
+	}
+}
+
+func TestBuildScripts(t *testing.T) {
+	testCases := []struct {
+		name    string
+		scripts packageScriptsJSON
+		env     *string
+		want    []string
+	}{
+		{
+			name:    "no scripts",
+			scripts: packageScriptsJSON{"start": "node index.js"},
+		},
+		{
+			name:    "gcp-build",
+			scripts: packageScriptsJSON{"gcp-build": "tsc", "build": "webpack"},
+			want:    []string{"gcp-build"},
+		},
+		{
+			name:    "build",
+			scripts: packageScriptsJSON{"build": "webpack"},
+			want:    []string{"build"},
+		},
+		{
+			name:    "from env",
+			scripts: packageScriptsJSON{"lint": "eslint .", "build": "webpack", "gcp-build": "tsc"},
+			env:     strPtr("lint, build,missing"),
+			want:    []string{"lint", "build"},
+		},
+		{
+			name:    "empty env",
+			scripts: packageScriptsJSON{"gcp-build": "tsc"},
+			env:     strPtr(""),
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if tc.env != nil {
+				defer setEnv(t, env.NodeRunScripts, *tc.env)()
+			}
+			p := PackageJSON{Scripts: tc.scripts}
+			if got := p.BuildScripts(); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("BuildScripts() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+// setEnv sets an environment variable and returns a function that unsets it.
+func setEnv(t *testing.T, key, value string) func() {
+	t.Helper()
+	if err := os.Setenv(key, value); err != nil {
+		t.Fatalf("setting %s: %v", key, err)
+	}
+	return func() {
+		os.Unsetenv(key)
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	// NPMRCBindingType is the type of service bindings providing an .npmrc entry with registry credentials.
+	NPMRCBindingType = "npmrc"
+	// YarnRCBindingType is the type of service bindings providing a .yarnrc.yml entry with registry credentials.
+	YarnRCBindingType = "yarnrc"
+	// npmrc is the name of the npm configuration file.
+	npmrc = ".npmrc"
+)
+
+// secretKeySuffixes are the suffixes of .npmrc and .yarnrc.yml keys whose values are credentials.
+var secretKeySuffixes = []string{"_authToken", "_auth", "_password", "npmAuthToken", "npmAuthIdent", "npmPassword"}
+
+// RegistryAuth makes the private registry credentials from service bindings of type npmrc and yarnrc and from
+// GOOGLE_NPMRC_FILE available to npm, yarn and pnpm. The .npmrc credentials are written to a temporary user
+// configuration outside of the application and layers, the .yarnrc.yml settings are set as YARN_* env vars, and both
+// are redacted from command output. The returned function removes them and must be called once dependencies are
+// installed; it is safe to call more than once.
+func RegistryAuth(ctx *gcp.Context) (func(), error) {
+	npmrcs, err := bindingEntries(ctx, NPMRCBindingType, npmrc)
+	if err != nil {
+		return nil, err
+	}
+	if f := os.Getenv(env.NPMRCFile); f != "" {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, gcp.UserErrorf("reading %s %q: %v", env.NPMRCFile, f, err)
+		}
+		npmrcs = append(npmrcs, string(data))
+	}
+	yarnrcs, err := bindingEntries(ctx, YarnRCBindingType, YarnRC)
+	if err != nil {
+		return nil, err
+	}
+	if len(npmrcs) == 0 && len(yarnrcs) == 0 {
+		return func() {}, nil
+	}
+	if len(yarnrcs) > 1 {
+		ctx.Warnf("Found %d bindings of type %s, only the first is used.", len(yarnrcs), YarnRCBindingType)
+	}
+
+	tmp := ""
+	restore := map[string]*string{}
+	setenv := func(k, v string) {
+		if old, ok := os.LookupEnv(k); ok {
+			restore[k] = &old
+		} else {
+			restore[k] = nil
+		}
+		ctx.Setenv(k, v)
+	}
+	if len(npmrcs) > 0 {
+		content := strings.Join(npmrcs, "\n")
+		redactRegistrySecrets(ctx, content)
+		tmp = ctx.TempDir("", "registry-auth-")
+		ctx.WriteFile(filepath.Join(tmp, npmrc), []byte(content), 0600)
+		setenv("NPM_CONFIG_USERCONFIG", filepath.Join(tmp, npmrc))
+	}
+	if len(yarnrcs) > 0 {
+		redactRegistrySecrets(ctx, yarnrcs[0])
+		// Yarn 2+ reads settings from YARN_* env vars, which leaves HOME and the application's .yarnrc.yml as they are.
+		settings := parseYarnRC(yarnrcs[0])
+		var keys []string
+		for k := range settings {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			v := settings[k]
+			if v == "" {
+				ctx.Warnf("Ignoring %s in the %s binding, only top-level settings can be set with env vars.", k, YarnRCBindingType)
+				continue
+			}
+			if strings.HasPrefix(v, "${") && strings.HasSuffix(v, "}") {
+				v = os.Getenv(strings.TrimSuffix(strings.TrimPrefix(v, "${"), "}"))
+			}
+			setenv(yarnEnvVar(k), v)
+		}
+	}
+	ctx.Logf("Using private registry credentials for installing dependencies.")
+
+	removed := false
+	return func() {
+		if removed {
+			return
+		}
+		removed = true
+		for k, v := range restore {
+			if v == nil {
+				os.Unsetenv(k)
+			} else {
+				ctx.Setenv(k, *v)
+			}
+		}
+		if tmp != "" {
+			ctx.RemoveAll(tmp)
+		}
+	}, nil
+}
+
+// yarnEnvVar returns the env var that sets a Yarn 2+ setting, e.g. YARN_NPM_AUTH_TOKEN for npmAuthToken.
+func yarnEnvVar(setting string) string {
+	var b strings.Builder
+	b.WriteString("YARN_")
+	for _, r := range setting {
+		if unicode.IsUpper(r) {
+			b.WriteRune('_')
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// bindingEntries returns the named entry of all service bindings of the given type.
+func bindingEntries(ctx *gcp.Context, bindingType, entry string) ([]string, error) {
+	bs, err := ctx.Bindings(bindingType)
+	if err != nil {
+		return nil, gcp.InternalErrorf("reading %s bindings: %v", bindingType, err)
+	}
+	var entries []string
+	for _, b := range bs {
+		data, err := b.Entry(entry)
+		if err != nil {
+			return nil, gcp.InternalErrorf("reading %s binding: %v", bindingType, err)
+		}
+		if data == nil {
+			return nil, gcp.UserErrorf("binding %s of type %s has no %s entry", b.Name, bindingType, entry)
+		}
+		entries = append(entries, string(data))
+	}
+	return entries, nil
+}
+
+// redactRegistrySecrets redacts the credentials of an .npmrc or .yarnrc.yml file, including the values of env vars
+// they reference, from the output of commands.
+func redactRegistrySecrets(ctx *gcp.Context, content string) {
+	for _, s := range registrySecrets(content) {
+		ctx.RedactSecret(s)
+	}
+}
+
+// registrySecrets returns the credentials of an .npmrc or .yarnrc.yml file.
+func registrySecrets(content string) []string {
+	var secrets []string
+	for _, line := range strings.Split(content, "\n") {
+		value, ok := secretValue(line, "=")
+		if !ok {
+			// .yarnrc.yml settings are key: value.
+			value, ok = secretValue(line, ":")
+		}
+		if !ok {
+			continue
+		}
+		if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+			value = os.Getenv(strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}"))
+			if value == "" {
+				continue
+			}
+		}
+		secrets = append(secrets, value)
+	}
+	return secrets
+}
+
+// secretValue returns the value of a line split on the first sep, if its key holds credentials.
+func secretValue(line, sep string) (string, bool) {
+	i := strings.Index(line, sep)
+	if i < 0 {
+		return "", false
+	}
+	key := strings.TrimSpace(line[:i])
+	value := strings.Trim(strings.TrimSpace(line[i+1:]), `"'`)
+	if value == "" {
+		return "", false
+	}
+	for _, s := range secretKeySuffixes {
+		if strings.HasSuffix(key, s) {
+			return value, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,193 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestRegistrySecrets(t *testing.T) {
+	defer os.Unsetenv("TEST_NPM_TOKEN")
+	if err := os.Setenv("TEST_NPM_TOKEN", "from-env"); err != nil {
+		t.Fatalf("setting env: %v", err)
+	}
+	testCases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{
+			name: "npmrc",
+			content: `registry=https://registry.example.com/
+@scope:registry=https://npm.pkg.github.com/
+//npm.pkg.github.com/:_authToken=ghp_secret
+//registry.example.com/:_auth="dXNlcjpwYXNz=="
+//registry.example.com/:_password=cGFzcw==
+//other.example.com/:_authToken=${TEST_NPM_TOKEN}
+//unset.example.com/:_authToken=${TEST_UNSET_TOKEN}
+always-auth=true
+`,
+			want: []string{"ghp_secret", "dXNlcjpwYXNz==", "cGFzcw==", "from-env"},
+		},
+		{
+			name: "yarnrc",
+			content: `npmScopes:
+  scope:
+    npmRegistryServer: "https://npm.pkg.github.com"
+    npmAuthToken: 'berry_secret'
+npmAuthIdent: dXNlcjpwYXNz==
+`,
+			want: []string{"berry_secret", "dXNlcjpwYXNz=="},
+		},
+		{
+			name:    "no credentials",
+			content: "registry=https://registry.npmjs.org/\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := registrySecrets(tc.content); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("registrySecrets() = %q, want %q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestRegistryAuth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-auth-test-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	npmrcFile := filepath.Join(dir, "npmrc")
+	if err := ioutil.WriteFile(npmrcFile, []byte("//registry.example.com/:_authToken=secret\n"), 0600); err != nil {
+		t.Fatalf("writing npmrc: %v", err)
+	}
+	for k, v := range map[string]string{env.NPMRCFile: npmrcFile, "SERVICE_BINDING_ROOT": filepath.Join(dir, "bindings")} {
+		defer os.Unsetenv(k)
+		if err := os.Setenv(k, v); err != nil {
+			t.Fatalf("setting %s: %v", k, err)
+		}
+	}
+	defer os.Unsetenv("NPM_CONFIG_USERCONFIG")
+	os.Unsetenv("NPM_CONFIG_USERCONFIG")
+
+	ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+	remove, err := RegistryAuth(ctx)
+	if err != nil {
+		t.Fatalf("RegistryAuth() got error: %v", err)
+	}
+	userconfig := os.Getenv("NPM_CONFIG_USERCONFIG")
+	data, err := ioutil.ReadFile(userconfig)
+	if err != nil {
+		t.Fatalf("reading NPM_CONFIG_USERCONFIG %q: %v", userconfig, err)
+	}
+	if want := "//registry.example.com/:_authToken=secret\n"; string(data) != want {
+		t.Errorf("user config = %q, want %q", data, want)
+	}
+	if out := ctx.Exec([]string{"echo", "token secret"}).Stdout; out != "token [REDACTED]" {
+		t.Errorf("Exec() output = %q, want secret redacted", out)
+	}
+
+	remove()
+	remove()
+	if v, ok := os.LookupEnv("NPM_CONFIG_USERCONFIG"); ok {
+		t.Errorf("NPM_CONFIG_USERCONFIG = %q after removal, want unset", v)
+	}
+	if _, err := os.Stat(userconfig); !os.IsNotExist(err) {
+		t.Errorf("%s exists after removal, want removed", userconfig)
+	}
+}
+
+func TestRegistryAuthYarnRC(t *testing.T) {
+	dir, err := ioutil.TempDir("", "registry-auth-test-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	binding := filepath.Join(dir, "bindings", "registry")
+	if err := os.MkdirAll(binding, 0755); err != nil {
+		t.Fatalf("creating binding: %v", err)
+	}
+	entries := map[string]string{
+		"type": YarnRCBindingType,
+		YarnRC: "npmRegistryServer: \"https://registry.example.com\"\nnpmAuthToken: secret\nnpmScopes:\n  example:\n    npmAlwaysAuth: true\n",
+	}
+	for f, c := range entries {
+		if err := ioutil.WriteFile(filepath.Join(binding, f), []byte(c), 0600); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	home := os.Getenv("HOME")
+	defer os.Unsetenv("SERVICE_BINDING_ROOT")
+	if err := os.Setenv("SERVICE_BINDING_ROOT", filepath.Join(dir, "bindings")); err != nil {
+		t.Fatalf("setting SERVICE_BINDING_ROOT: %v", err)
+	}
+
+	ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+	remove, err := RegistryAuth(ctx)
+	if err != nil {
+		t.Fatalf("RegistryAuth() got error: %v", err)
+	}
+	want := map[string]string{
+		"YARN_NPM_REGISTRY_SERVER": "https://registry.example.com",
+		"YARN_NPM_AUTH_TOKEN":      "secret",
+		"HOME":                     home,
+	}
+	for k, v := range want {
+		if got := os.Getenv(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if v, ok := os.LookupEnv("YARN_NPM_SCOPES"); ok {
+		t.Errorf("YARN_NPM_SCOPES = %q, want unset", v)
+	}
+	if out := ctx.Exec([]string{"echo", "token secret"}).Stdout; out != "token [REDACTED]" {
+		t.Errorf("Exec() output = %q, want secret redacted", out)
+	}
+
+	remove()
+	for _, k := range []string{"YARN_NPM_REGISTRY_SERVER", "YARN_NPM_AUTH_TOKEN"} {
+		if v, ok := os.LookupEnv(k); ok {
+			t.Errorf("%s = %q after removal, want unset", k, v)
+		}
+	}
+}
+
+func TestYarnEnvVar(t *testing.T) {
+	testCases := []struct {
+		setting string
+		want    string
+	}{
+		{setting: "npmAuthToken", want: "YARN_NPM_AUTH_TOKEN"},
+		{setting: "npmRegistryServer", want: "YARN_NPM_REGISTRY_SERVER"},
+		{setting: "enableStrictSsl", want: "YARN_ENABLE_STRICT_SSL"},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.setting, func(t *testing.T) {
+			if got := yarnEnvVar(tc.setting); got != tc.want {
+				t.Errorf("yarnEnvVar(%q) = %q, want %q", tc.setting, got, tc.want)
+			}
+		})
+	}
+}
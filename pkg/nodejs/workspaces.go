@@ -0,0 +1,180 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// workspacesJSON represents the workspaces field of package.json, either a list of patterns or, for yarn, an object
+// with a packages list.
+type workspacesJSON []string
+
+// UnmarshalJSON accepts both forms of the workspaces field.
+func (w *workspacesJSON) UnmarshalJSON(data []byte) error {
+	var patterns []string
+	if err := json.Unmarshal(data, &patterns); err == nil {
+		*w = patterns
+		return nil
+	}
+	var obj struct {
+		Packages []string `json:"packages"`
+	}
+	if err := json.Unmarshal(data, &obj); err != nil {
+		return fmt.Errorf("workspaces must be a list or an object with packages: %v", err)
+	}
+	*w = obj.Packages
+	return nil
+}
+
+// Workspace is a package of a workspace monorepo.
+type Workspace struct {
+	// Dir is the directory of the workspace relative to the application root.
+	Dir     string
+	Package *PackageJSON
+}
+
+// ReadWorkspaces returns the workspaces declared in the package.json in dir, sorted by directory.
+func ReadWorkspaces(dir string) ([]Workspace, error) {
+	root, err := ReadPackageJSON(dir)
+	if err != nil {
+		return nil, err
+	}
+	seen := map[string]bool{}
+	var wss []Workspace
+	for _, pattern := range root.Workspaces {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, gcp.UserErrorf("invalid workspaces pattern %q: %v", pattern, err)
+		}
+		for _, m := range matches {
+			if _, err := os.Stat(filepath.Join(m, "package.json")); err != nil {
+				continue
+			}
+			rel, err := filepath.Rel(dir, m)
+			if err != nil {
+				return nil, gcp.InternalErrorf("finding relative path of %s: %v", m, err)
+			}
+			if seen[rel] {
+				continue
+			}
+			seen[rel] = true
+			pjs, err := ReadPackageJSON(m)
+			if err != nil {
+				return nil, err
+			}
+			wss = append(wss, Workspace{Dir: rel, Package: pjs})
+		}
+	}
+	sort.Slice(wss, func(i, j int) bool { return wss[i].Dir < wss[j].Dir })
+	return wss, nil
+}
+
+// BuildableWorkspaces returns the workspace selected by GOOGLE_BUILDABLE, by directory or package name, preceded by
+// the workspaces it depends on in build order. It returns nil if GOOGLE_BUILDABLE is not set.
+func BuildableWorkspaces(ctx *gcp.Context) ([]Workspace, error) {
+	buildable := os.Getenv(env.Buildable)
+	if buildable == "" {
+		return nil, nil
+	}
+	wss, err := ReadWorkspaces(ctx.ApplicationRoot())
+	if err != nil {
+		return nil, err
+	}
+	return selectWorkspaces(wss, buildable)
+}
+
+// selectWorkspaces returns the workspace matching buildable preceded by its transitive workspace dependencies,
+// dependencies first.
+func selectWorkspaces(wss []Workspace, buildable string) ([]Workspace, error) {
+	byName := map[string]Workspace{}
+	var selected *Workspace
+	for i, ws := range wss {
+		if ws.Package.Name != "" {
+			byName[ws.Package.Name] = ws
+		}
+		if ws.Dir == filepath.Clean(buildable) || ws.Package.Name == buildable {
+			selected = &wss[i]
+		}
+	}
+	if selected == nil {
+		return nil, gcp.UserErrorf("%s=%q does not match the directory or name of any workspace in package.json", env.Buildable, buildable)
+	}
+
+	var ordered []Workspace
+	state := map[string]int{} // 1 while visiting, 2 once ordered.
+	var visit func(ws Workspace) error
+	visit = func(ws Workspace) error {
+		switch state[ws.Dir] {
+		case 1:
+			return gcp.UserErrorf("workspace %s has a circular dependency", ws.Dir)
+		case 2:
+			return nil
+		}
+		state[ws.Dir] = 1
+		var deps []string
+		for _, m := range []map[string]string{ws.Package.Dependencies, ws.Package.DevDependencies} {
+			for name := range m {
+				if _, ok := byName[name]; ok {
+					deps = append(deps, name)
+				}
+			}
+		}
+		sort.Strings(deps)
+		for _, name := range deps {
+			if err := visit(byName[name]); err != nil {
+				return err
+			}
+		}
+		state[ws.Dir] = 2
+		ordered = append(ordered, ws)
+		return nil
+	}
+	if err := visit(*selected); err != nil {
+		return nil, err
+	}
+	return ordered, nil
+}
+
+// HasBuildScripts returns true if any of the workspaces defines a script to run during the build.
+func HasBuildScripts(wss []Workspace) bool {
+	for _, ws := range wss {
+		if len(ws.Package.BuildScripts()) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// BuildWorkspaces runs the build scripts of each workspace, in order, with the package manager's run command,
+// e.g. `npm run`.
+func BuildWorkspaces(ctx *gcp.Context, wss []Workspace, run []string) {
+	for _, ws := range wss {
+		for _, script := range ws.Package.BuildScripts() {
+			ctx.Logf("Running %s in workspace %s", script, ws.Dir)
+			ctx.ExecUserWithParams(gcp.ExecParams{
+				Cmd: append(append([]string{}, run...), script),
+				Dir: filepath.Join(ctx.ApplicationRoot(), ws.Dir),
+			}, gcp.UserErrorKeepStderrTail)
+		}
+	}
+}
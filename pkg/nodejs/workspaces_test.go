@@ -0,0 +1,143 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestReadWorkspaces(t *testing.T) {
+	testCases := []struct {
+		name  string
+		files map[string]string
+		want  []string
+	}{
+		{
+			name: "no workspaces",
+			files: map[string]string{
+				"package.json": `{"name": "app"}`,
+			},
+		},
+		{
+			name: "list of patterns",
+			files: map[string]string{
+				"package.json":              `{"workspaces": ["packages/*", "tools/cli"]}`,
+				"packages/web/package.json": `{"name": "web"}`,
+				"packages/api/package.json": `{"name": "api"}`,
+				"packages/docs/README.md":   "",
+				"tools/cli/package.json":    `{"name": "cli"}`,
+			},
+			want: []string{"packages/api", "packages/web", "tools/cli"},
+		},
+		{
+			name: "yarn packages object",
+			files: map[string]string{
+				"package.json":              `{"workspaces": {"packages": ["packages/*"], "nohoist": ["**/react"]}}`,
+				"packages/api/package.json": `{"name": "api"}`,
+			},
+			want: []string{"packages/api"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := writeFiles(t, tc.files)
+			defer os.RemoveAll(dir)
+
+			wss, err := ReadWorkspaces(dir)
+			if err != nil {
+				t.Fatalf("ReadWorkspaces() got error: %v", err)
+			}
+			var got []string
+			for _, ws := range wss {
+				got = append(got, ws.Dir)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("ReadWorkspaces() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectWorkspaces(t *testing.T) {
+	wss := []Workspace{
+		{Dir: "packages/api", Package: &PackageJSON{Name: "@app/api", Dependencies: map[string]string{"@app/db": "*", "express": "^4"}}},
+		{Dir: "packages/db", Package: &PackageJSON{Name: "@app/db", DevDependencies: map[string]string{"@app/types": "*"}}},
+		{Dir: "packages/types", Package: &PackageJSON{Name: "@app/types"}},
+		{Dir: "packages/web", Package: &PackageJSON{Name: "@app/web", Dependencies: map[string]string{"@app/types": "*"}}},
+	}
+	testCases := []struct {
+		buildable string
+		want      []string
+		wantErr   bool
+	}{
+		{buildable: "packages/api", want: []string{"packages/types", "packages/db", "packages/api"}},
+		{buildable: "./packages/web/", want: []string{"packages/types", "packages/web"}},
+		{buildable: "@app/types", want: []string{"packages/types"}},
+		{buildable: "packages/missing", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.buildable, func(t *testing.T) {
+			selected, err := selectWorkspaces(wss, tc.buildable)
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("selectWorkspaces(%q) got no error, want error", tc.buildable)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("selectWorkspaces(%q) got error: %v", tc.buildable, err)
+			}
+			var got []string
+			for _, ws := range selected {
+				got = append(got, ws.Dir)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("selectWorkspaces(%q) = %v, want %v", tc.buildable, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestSelectWorkspacesCycle(t *testing.T) {
+	wss := []Workspace{
+		{Dir: "a", Package: &PackageJSON{Name: "a", Dependencies: map[string]string{"b": "*"}}},
+		{Dir: "b", Package: &PackageJSON{Name: "b", Dependencies: map[string]string{"a": "*"}}},
+	}
+	if _, err := selectWorkspaces(wss, "a"); err == nil {
+		t.Error("selectWorkspaces() got no error, want circular dependency error")
+	}
+}
+
+// writeFiles creates a temporary directory containing files.
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "workspaces-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	for f, c := range files {
+		if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+			t.Fatalf("creating dir for %s: %v", f, err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, f), []byte(c), 0644); err != nil {
+			t.Fatalf("writing %s: %v", f, err)
+		}
+	}
+	return dir
+}
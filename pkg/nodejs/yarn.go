@@ -15,16 +15,39 @@
 package nodejs
 
 import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpackplan"
+	"github.com/buildpack/libbuildpack/layers"
 )
 
 const (
 	// YarnLock is the name of the yarn lock file.
 	YarnLock = "yarn.lock"
+	// YarnRC is the name of the configuration file of Yarn 2 and later (Berry).
+	YarnRC = ".yarnrc.yml"
+	// defaultYarnCacheFolder is the default cache folder of Yarn Berry, relative to the application root.
+	defaultYarnCacheFolder = ".yarn/cache"
+	yarnLayer              = "yarn_install"
+	yarnURL                = "https://github.com/yarnpkg/yarn/releases/download/v%[1]s/yarn-v%[1]s.tar.gz"
 )
 
+// yarnReleaseRegexp matches the major version of a checked-in Yarn release, e.g. yarn-4.0.2.cjs.
+var yarnReleaseRegexp = regexp.MustCompile(`^yarn-(\d+)\.`)
+
+// yarnMetadata represents metadata stored for a yarn layer.
+type yarnMetadata struct {
+	Version string `toml:"version"`
+}
+
 // LockfileFlag returns an appropriate lockfile handling flag, including empty string.
 func LockfileFlag(ctx *gcp.Context) string {
 	// HACK: For backwards compatibility on App Engine Node.js 10, skip using `--frozen-lockfile`.
@@ -34,3 +57,209 @@ func LockfileFlag(ctx *gcp.Context) string {
 
 	return "--frozen-lockfile"
 }
+
+// InstallYarn installs the latest stable release of Yarn 1 in a layer with the given flags and adds it to PATH,
+// unless yarn is already installed.
+func InstallYarn(ctx *gcp.Context, flags ...layers.Flag) error {
+	// Skip installation if yarn is already installed.
+	if result := ctx.Exec([]string{"bash", "-c", "command -v yarn || true"}); result.Stdout != "" {
+		ctx.Debugf("Yarn is already installed, skipping installation.")
+		return nil
+	}
+
+	// Use semver.io to determine the latest available version of Yarn.
+	ctx.Logf("Finding latest stable version of Yarn.")
+	result := ctx.Exec([]string{"curl", "--silent", "--get", "http://semver.io/yarn/stable"})
+	version := result.Stdout
+	ctx.Logf("The latest stable version of Yarn is v%s", version)
+
+	yrl := ctx.Layer(yarnLayer)
+
+	// Check the metadata in the cache layer to determine if we need to proceed.
+	var meta yarnMetadata
+	ctx.ReadMetadata(yrl, &meta)
+	if version == meta.Version {
+		ctx.CacheHit(yarnLayer)
+		ctx.Logf("Yarn cache hit, skipping installation.")
+	} else {
+		ctx.CacheMiss(yarnLayer)
+		ctx.ClearLayer(yrl)
+
+		// Download and install yarn in layer.
+		ctx.Logf("Installing Yarn v%s", version)
+		archiveURL := fmt.Sprintf(yarnURL, version)
+		command := fmt.Sprintf("curl --fail --show-error --silent --location --retry 3 %s | tar xz --directory %s --strip-components=1", archiveURL, yrl.Root)
+		ctx.Exec([]string{"bash", "-c", command})
+	}
+
+	// Store layer flags and metadata.
+	meta.Version = version
+	ctx.WriteMetadata(yrl, meta, flags...)
+	ctx.Setenv("PATH", filepath.Join(yrl.Root, "bin")+":"+os.Getenv("PATH"))
+
+	ctx.AddBuildpackPlan(buildpackplan.Plan{
+		Name:    yarnLayer,
+		Version: version,
+	})
+	return nil
+}
+
+// InstallYarnBerryDependencies installs dependencies with the Yarn 2+ release checked into the application and
+// returns the command to run it. Unless the Yarn cache is checked in, it is kept in the named cache layer.
+func InstallYarnBerryDependencies(ctx *gcp.Context, cacheLayer, nodeEnv string) ([]string, error) {
+	rc, err := ReadYarnRC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	release := rc.Release(ctx)
+	if release == "" {
+		return nil, gcp.UserErrorf("%s found but no Yarn release is checked in, run `yarn set version` and commit the release in .yarn/releases", YarnRC)
+	}
+	ctx.Logf("Using Yarn release %s", release)
+	yarn := []string{"node", filepath.Join(ctx.ApplicationRoot(), release)}
+
+	cmd := append(yarn, "install", "--immutable")
+	focus := false
+	if nodeEnv == EnvProduction {
+		if focus = hasWorkspaceTools(ctx, release); focus {
+			// Unlike Yarn 1, `yarn install` has no --production flag.
+			cmd = append(yarn, "workspaces", "focus", "--all", "--production")
+		} else {
+			ctx.Logf("Installing devDependencies, run `yarn plugin import workspace-tools` to install production dependencies only.")
+		}
+	}
+	var cl *layers.Layer
+	cacheFolder := rc.CacheFolder()
+	if rc.CacheCheckedIn(ctx) {
+		// Zero-installs: the cache is committed with the application and must match yarn.lock.
+		ctx.Logf("Using the Yarn cache checked in at %s.", cacheFolder)
+		if !focus {
+			cmd = append(cmd, "--immutable-cache")
+		}
+	} else {
+		// The cache is kept in the application so that Plug'n'Play can load packages from it at launch,
+		// and is copied to a layer to be reused by the next build.
+		cl = ctx.Layer(cacheLayer)
+		if len(ctx.Glob(filepath.Join(cl.Root, "*.zip"))) > 0 {
+			ctx.CacheHit(cacheLayer)
+			ctx.MkdirAll(cacheFolder, 0755)
+			ctx.Exec([]string{"cp", "--archive", cl.Root + "/.", cacheFolder})
+		} else {
+			ctx.CacheMiss(cacheLayer)
+		}
+	}
+
+	CacheNodeGypHeaders(ctx)
+	ctx.ExecUserWithParams(gcp.ExecParams{
+		Cmd: cmd,
+		Env: []string{"NODE_ENV=" + nodeEnv, "YARN_ENABLE_GLOBAL_CACHE=false"},
+	}, gcp.UserErrorKeepStderrTail)
+
+	if cl != nil {
+		ctx.ClearLayer(cl)
+		if ctx.FileExists(ctx.ApplicationRoot(), cacheFolder) {
+			ctx.Exec([]string{"cp", "--archive", cacheFolder + "/.", cl.Root})
+		}
+		ctx.WriteMetadata(cl, nil, layers.Cache)
+	}
+
+	return yarn, nil
+}
+
+// hasWorkspaceTools returns true if the Yarn release supports `yarn workspaces focus`, which is built into Yarn 4 and
+// provided by the workspace-tools plugin in earlier releases.
+func hasWorkspaceTools(ctx *gcp.Context, release string) bool {
+	if ctx.FileExists(ctx.ApplicationRoot(), ".yarn", "plugins", "@yarnpkg", "plugin-workspace-tools.cjs") {
+		return true
+	}
+	m := yarnReleaseRegexp.FindStringSubmatch(filepath.Base(release))
+	if m == nil {
+		return false
+	}
+	major, err := strconv.Atoi(m[1])
+	return err == nil && major >= 4
+}
+
+// IsYarnBerry returns true if the application uses Yarn 2 or later.
+func IsYarnBerry(ctx *gcp.Context) bool {
+	return ctx.FileExists(ctx.ApplicationRoot(), YarnRC)
+}
+
+// YarnRCSettings holds the top-level settings of .yarnrc.yml used during the build.
+type YarnRCSettings map[string]string
+
+// ReadYarnRC reads the top-level scalar settings of .yarnrc.yml in the application root.
+func ReadYarnRC(ctx *gcp.Context) (YarnRCSettings, error) {
+	data, err := ioutil.ReadFile(filepath.Join(ctx.ApplicationRoot(), YarnRC))
+	if err != nil {
+		return nil, fmt.Errorf("reading %s: %w", YarnRC, err)
+	}
+	return parseYarnRC(string(data)), nil
+}
+
+// parseYarnRC parses the top-level `key: value` lines of .yarnrc.yml, ignoring nested mappings and lists.
+func parseYarnRC(data string) YarnRCSettings {
+	settings := YarnRCSettings{}
+	for _, line := range strings.Split(data, "\n") {
+		if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '#' || line[0] == '-' {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		value := strings.TrimSpace(parts[1])
+		if i := strings.Index(value, " #"); i >= 0 {
+			value = strings.TrimSpace(value[:i])
+		}
+		settings[strings.TrimSpace(parts[0])] = strings.Trim(value, `"'`)
+	}
+	return settings
+}
+
+// Release returns the path of the checked-in Yarn release relative to the application root, set by yarnPath or found
+// in .yarn/releases. It returns an empty string if there is none.
+func (s YarnRCSettings) Release(ctx *gcp.Context) string {
+	if p := s["yarnPath"]; p != "" && ctx.FileExists(ctx.ApplicationRoot(), p) {
+		return p
+	}
+	releases := ctx.Glob(filepath.Join(ctx.ApplicationRoot(), ".yarn", "releases", "yarn-*"))
+	if len(releases) == 0 {
+		return ""
+	}
+	sort.Strings(releases)
+	rel, err := filepath.Rel(ctx.ApplicationRoot(), releases[len(releases)-1])
+	if err != nil {
+		return ""
+	}
+	return rel
+}
+
+// CacheFolder returns the Yarn cache folder relative to the application root.
+func (s YarnRCSettings) CacheFolder() string {
+	if f := s["cacheFolder"]; f != "" {
+		return f
+	}
+	return defaultYarnCacheFolder
+}
+
+// CacheCheckedIn returns true if the Yarn cache is checked into the application (zero-installs).
+func (s YarnRCSettings) CacheCheckedIn(ctx *gcp.Context) bool {
+	return len(ctx.Glob(filepath.Join(ctx.ApplicationRoot(), s.CacheFolder(), "*.zip"))) > 0
+}
+
+// PnP returns true if dependencies are installed with Plug'n'Play rather than in node_modules.
+func (s YarnRCSettings) PnP() bool {
+	linker := s["nodeLinker"]
+	return linker == "" || linker == "pnp"
+}
+
+// PnPLoader returns the path of the Plug'n'Play loader generated by Yarn Berry, or an empty string if there is none.
+func PnPLoader(ctx *gcp.Context) string {
+	for _, f := range []string{".pnp.cjs", ".pnp.js"} {
+		if ctx.FileExists(ctx.ApplicationRoot(), f) {
+			return filepath.Join(ctx.ApplicationRoot(), f)
+		}
+	}
+	return ""
+}
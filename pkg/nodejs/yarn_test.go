@@ -0,0 +1,174 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nodejs
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestParseYarnRC(t *testing.T) {
+	data := `# Generated by yarn set version.
+yarnPath: .yarn/releases/yarn-2.4.0.cjs
+nodeLinker: "node-modules"
+cacheFolder: './cache' # committed
+packageExtensions:
+  "debug@*":
+    dependencies:
+      supports-color: "*"
+plugins:
+  - path: .yarn/plugins/@yarnpkg/plugin-workspace-tools.cjs
+`
+	want := YarnRCSettings{
+		"yarnPath":          ".yarn/releases/yarn-2.4.0.cjs",
+		"nodeLinker":        "node-modules",
+		"cacheFolder":       "./cache",
+		"packageExtensions": "",
+		"plugins":           "",
+	}
+	if got := parseYarnRC(data); !reflect.DeepEqual(got, want) {
+		t.Errorf("parseYarnRC() = %v, want %v", got, want)
+	}
+}
+
+func TestYarnRCSettings(t *testing.T) {
+	testCases := []struct {
+		name               string
+		settings           YarnRCSettings
+		files              []string
+		wantRelease        string
+		wantCacheFolder    string
+		wantCacheCheckedIn bool
+		wantPnP            bool
+	}{
+		{
+			name:            "defaults",
+			settings:        YarnRCSettings{},
+			wantCacheFolder: ".yarn/cache",
+			wantPnP:         true,
+		},
+		{
+			name:            "yarnPath",
+			settings:        YarnRCSettings{"yarnPath": "tools/yarn.cjs", "nodeLinker": "pnp"},
+			files:           []string{"tools/yarn.cjs", ".yarn/releases/yarn-2.4.0.cjs"},
+			wantRelease:     "tools/yarn.cjs",
+			wantCacheFolder: ".yarn/cache",
+			wantPnP:         true,
+		},
+		{
+			name:            "latest checked-in release",
+			settings:        YarnRCSettings{"nodeLinker": "node-modules", "cacheFolder": "cache"},
+			files:           []string{".yarn/releases/yarn-2.3.3.cjs", ".yarn/releases/yarn-2.4.0.cjs"},
+			wantRelease:     ".yarn/releases/yarn-2.4.0.cjs",
+			wantCacheFolder: "cache",
+		},
+		{
+			name:               "checked-in cache",
+			settings:           YarnRCSettings{"cacheFolder": "cache"},
+			files:              []string{"cache/left-pad-npm-1.3.0-a1b2c3.zip"},
+			wantCacheFolder:    "cache",
+			wantCacheCheckedIn: true,
+			wantPnP:            true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "yarnrc-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, f := range tc.files {
+				if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			if got := tc.settings.Release(ctx); got != tc.wantRelease {
+				t.Errorf("Release() = %q, want %q", got, tc.wantRelease)
+			}
+			if got := tc.settings.CacheFolder(); got != tc.wantCacheFolder {
+				t.Errorf("CacheFolder() = %q, want %q", got, tc.wantCacheFolder)
+			}
+			if got := tc.settings.CacheCheckedIn(ctx); got != tc.wantCacheCheckedIn {
+				t.Errorf("CacheCheckedIn() = %t, want %t", got, tc.wantCacheCheckedIn)
+			}
+			if got := tc.settings.PnP(); got != tc.wantPnP {
+				t.Errorf("PnP() = %t, want %t", got, tc.wantPnP)
+			}
+		})
+	}
+}
+
+func TestHasWorkspaceTools(t *testing.T) {
+	testCases := []struct {
+		name    string
+		release string
+		files   []string
+		want    bool
+	}{
+		{
+			name:    "yarn 2 without plugin",
+			release: ".yarn/releases/yarn-2.4.0.cjs",
+		},
+		{
+			name:    "yarn 2 with plugin",
+			release: ".yarn/releases/yarn-2.4.0.cjs",
+			files:   []string{".yarn/plugins/@yarnpkg/plugin-workspace-tools.cjs"},
+			want:    true,
+		},
+		{
+			name:    "yarn 4",
+			release: ".yarn/releases/yarn-4.0.2.cjs",
+			want:    true,
+		},
+		{
+			name:    "custom yarnPath",
+			release: "tools/yarn.cjs",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "yarn-")
+			if err != nil {
+				t.Fatalf("creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, f := range tc.files {
+				if err := os.MkdirAll(filepath.Join(dir, filepath.Dir(f)), 0755); err != nil {
+					t.Fatalf("creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(filepath.Join(dir, f), nil, 0644); err != nil {
+					t.Fatalf("writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContextForTests(buildpack.Info{}, dir)
+
+			if got := hasWorkspaceTools(ctx, tc.release); got != tc.want {
+				t.Errorf("hasWorkspaceTools(%q) = %t, want %t", tc.release, got, tc.want)
+			}
+		})
+	}
+}
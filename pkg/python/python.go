@@ -17,6 +17,8 @@ package python
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/GoogleCloudPlatform/buildpacks/pkg/cache"
@@ -30,6 +32,12 @@ type Metadata struct {
 	DependencyHash string `toml:"dependency_hash"`
 }
 
+// toolMetadata represents metadata stored for a layer holding a Python tool.
+type toolMetadata struct {
+	Version       string `toml:"version"`
+	PythonVersion string `toml:"python_version"`
+}
+
 // Version returns the installed version of Python.
 func Version(ctx *gcp.Context) string {
 	result := ctx.Exec([]string{"python3", "--version"})
@@ -66,3 +74,48 @@ func CheckCache(ctx *gcp.Context, l *layers.Layer, opts ...cache.Option) (bool,
 
 	return false, &meta, nil
 }
+
+// InstallRequirements installs the packages of a requirements file into the layer with pip, adds the layer to
+// PYTHONPATH and checks that the installed packages have compatible dependencies.
+func InstallRequirements(ctx *gcp.Context, l *layers.Layer, req string) error {
+	ctx.Logf("Running pip install.")
+	ctx.ExecUser([]string{"python3", "-m", "pip", "install", "--upgrade", "-r", req, "-t", l.Root})
+
+	ctx.PrependPathSharedEnv(l, "PYTHONPATH", l.Root)
+
+	// Check for broken dependencies.
+	ctx.Logf("Checking for incompatible dependencies.")
+	checkDeps := ctx.ExecWithParams(gcp.ExecParams{
+		Cmd: []string{"python3", "-m", "pip", "check"},
+		Env: []string{"PYTHONPATH=" + l.Root + ":" + os.Getenv("PYTHONPATH")},
+	})
+	if checkDeps.ExitCode != 0 {
+		return fmt.Errorf("incompatible dependencies installed: %q", checkDeps.Stdout)
+	}
+	return nil
+}
+
+// InstallTool installs the given version of a Python package providing a command-line tool, such as Poetry, in a
+// virtual environment in a cached layer, and returns the path of its executable.
+func InstallTool(ctx *gcp.Context, layerName, name, version string) string {
+	l := ctx.Layer(layerName)
+	tool := filepath.Join(l.Root, "bin", name)
+	pythonVersion := Version(ctx)
+
+	var meta toolMetadata
+	ctx.ReadMetadata(l, &meta)
+	if meta.Version == version && meta.PythonVersion == pythonVersion && ctx.FileExists(tool) {
+		ctx.CacheHit(layerName)
+		return tool
+	}
+	ctx.CacheMiss(layerName)
+	ctx.ClearLayer(l)
+
+	ctx.Logf("Installing %s v%s.", name, version)
+	ctx.Exec([]string{"python3", "-m", "venv", l.Root})
+	ctx.Exec([]string{filepath.Join(l.Root, "bin", "pip"), "install", "--quiet", name + "==" + version})
+
+	meta = toolMetadata{Version: version, PythonVersion: pythonVersion}
+	ctx.WriteMetadata(l, &meta, layers.Cache)
+	return tool
+}
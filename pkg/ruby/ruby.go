@@ -0,0 +1,46 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ruby contains Ruby buildpack library code.
+package ruby
+
+import (
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+const (
+	bundleIndicator  = "Gemfile.lock"
+	bundle2Indicator = "gems.locked"
+	railsIndicator   = "bin/rails"
+	railsCommand     = "bin/rails server"
+	rackIndicator    = "config.ru"
+	rackCommand      = "rackup --port $PORT"
+)
+
+// InferEntrypoint returns the command that starts the Rails or Rack application in srcDir, run with `bundle exec` if
+// the application has a bundle. It returns false if the application is neither.
+func InferEntrypoint(ctx *gcp.Context, srcDir string) (string, bool) {
+	var cmd string
+	if ctx.FileExists(srcDir, railsIndicator) {
+		cmd = railsCommand
+	} else if ctx.FileExists(srcDir, rackIndicator) {
+		cmd = rackCommand
+	} else {
+		return "", false
+	}
+	if ctx.FileExists(srcDir, bundleIndicator) || ctx.FileExists(srcDir, bundle2Indicator) {
+		cmd = "bundle exec " + cmd
+	}
+	return cmd, true
+}
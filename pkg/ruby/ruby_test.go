@@ -0,0 +1,87 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruby
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestInferEntrypoint(t *testing.T) {
+	testCases := []struct {
+		name   string
+		files  []string
+		want   string
+		wantOK bool
+	}{
+		{
+			name:   "rails",
+			files:  []string{"bin/rails"},
+			want:   "bin/rails server",
+			wantOK: true,
+		},
+		{
+			name:   "rails with Gemfile.lock",
+			files:  []string{"bin/rails", "config.ru", "Gemfile.lock"},
+			want:   "bundle exec bin/rails server",
+			wantOK: true,
+		},
+		{
+			name:   "rack",
+			files:  []string{"config.ru"},
+			want:   "rackup --port $PORT",
+			wantOK: true,
+		},
+		{
+			name:   "rack with gems.locked",
+			files:  []string{"config.ru", "gems.locked"},
+			want:   "bundle exec rackup --port $PORT",
+			wantOK: true,
+		},
+		{
+			name:  "neither",
+			files: []string{"app.rb", "Gemfile.lock"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "ruby-entrypoint-")
+			if err != nil {
+				t.Fatalf("Creating temp dir: %v", err)
+			}
+			defer os.RemoveAll(dir)
+			for _, f := range tc.files {
+				fn := filepath.Join(dir, f)
+				if err := os.MkdirAll(filepath.Dir(fn), 0755); err != nil {
+					t.Fatalf("Creating dir for %s: %v", f, err)
+				}
+				if err := ioutil.WriteFile(fn, []byte{}, 0644); err != nil {
+					t.Fatalf("Writing %s: %v", f, err)
+				}
+			}
+			ctx := gcp.NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+
+			got, ok := InferEntrypoint(ctx, dir)
+			if got != tc.want || ok != tc.wantOK {
+				t.Errorf("InferEntrypoint() = %q, %t, want %q, %t", got, ok, tc.want, tc.wantOK)
+			}
+		})
+	}
+}
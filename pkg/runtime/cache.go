@@ -0,0 +1,91 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"path/filepath"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/layers"
+)
+
+// cachedVersions is the number of most recently used versions kept in a version cache.
+const cachedVersions = 3
+
+// versionCacheMetadata represents metadata stored for a version cache layer.
+type versionCacheMetadata struct {
+	// Versions lists the cached versions, most recently used first.
+	Versions []string `toml:"versions"`
+}
+
+// InstallVersion clears l and fills it with an installation of version. Installations are kept in a cache-only layer,
+// named after l with a `-versions` suffix, which holds the most recently used versions so that switching between
+// them does not require a download. If version is not cached, install is called to install it into an empty
+// directory of the cache. The cached installation is copied into l, so that changes to l do not affect the cache.
+func InstallVersion(ctx *gcp.Context, l *layers.Layer, version string, install func(dir string) error) error {
+	name := filepath.Base(l.Root) + "-versions"
+	cl := ctx.Layer(name)
+	var meta versionCacheMetadata
+	ctx.ReadMetadata(cl, &meta)
+
+	dir := filepath.Join(cl.Root, version)
+	if contains(meta.Versions, version) && ctx.FileExists(dir) {
+		ctx.CacheHit(name)
+		ctx.Logf("Using cached version %s.", version)
+	} else {
+		ctx.CacheMiss(name)
+		ctx.RemoveAll(dir)
+		ctx.MkdirAll(dir, 0755)
+		if err := install(dir); err != nil {
+			ctx.RemoveAll(dir)
+			return err
+		}
+	}
+
+	var evicted []string
+	meta.Versions, evicted = recordVersion(meta.Versions, version, cachedVersions)
+	for _, v := range evicted {
+		ctx.Debugf("Evicting version %s from %s.", v, name)
+		ctx.RemoveAll(filepath.Join(cl.Root, v))
+	}
+	ctx.WriteMetadata(cl, meta, layers.Cache)
+
+	ctx.ClearLayer(l)
+	ctx.Exec([]string{"cp", "--archive", dir + string(filepath.Separator) + ".", l.Root})
+	return nil
+}
+
+// recordVersion moves version to the front of versions, returning the n most recently used versions and the others.
+func recordVersion(versions []string, version string, n int) ([]string, []string) {
+	recent := []string{version}
+	for _, v := range versions {
+		if v != version {
+			recent = append(recent, v)
+		}
+	}
+	if len(recent) <= n {
+		return recent, nil
+	}
+	return recent[:n], recent[n:]
+}
+
+func contains(versions []string, version string) bool {
+	for _, v := range versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
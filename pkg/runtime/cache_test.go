@@ -0,0 +1,147 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestRecordVersion(t *testing.T) {
+	testCases := []struct {
+		name        string
+		versions    []string
+		version     string
+		want        []string
+		wantEvicted []string
+	}{
+		{
+			name:    "empty",
+			version: "12.18.3",
+			want:    []string{"12.18.3"},
+		},
+		{
+			name:     "new version",
+			versions: []string{"12.18.3", "14.8.0"},
+			version:  "10.22.0",
+			want:     []string{"10.22.0", "12.18.3", "14.8.0"},
+		},
+		{
+			name:     "cached version moves to front",
+			versions: []string{"12.18.3", "14.8.0", "10.22.0"},
+			version:  "10.22.0",
+			want:     []string{"10.22.0", "12.18.3", "14.8.0"},
+		},
+		{
+			name:        "least recently used evicted",
+			versions:    []string{"12.18.3", "14.8.0", "10.22.0"},
+			version:     "14.9.0",
+			want:        []string{"14.9.0", "12.18.3", "14.8.0"},
+			wantEvicted: []string{"10.22.0"},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, gotEvicted := recordVersion(tc.versions, tc.version, 3)
+			if !reflect.DeepEqual(got, tc.want) || !reflect.DeepEqual(gotEvicted, tc.wantEvicted) {
+				t.Errorf("recordVersion(%v, %q, 3) = %v, %v, want %v, %v", tc.versions, tc.version, got, gotEvicted, tc.want, tc.wantEvicted)
+			}
+		})
+	}
+}
+
+func TestInstallVersion(t *testing.T) {
+	layersDir, err := ioutil.TempDir("", "layers-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layersDir)
+	ctx := gcp.NewContextForTestsWithLayers(buildpack.Info{ID: "id", Version: "version", Name: "name"}, layersDir, layersDir)
+	l := ctx.Layer("node")
+
+	var installed []string
+	install := func(version string) {
+		t.Helper()
+		if err := InstallVersion(ctx, l, version, func(dir string) error {
+			installed = append(installed, version)
+			return ioutil.WriteFile(filepath.Join(dir, "version"), []byte(version), 0644)
+		}); err != nil {
+			t.Fatalf("InstallVersion(%q) got error: %v", version, err)
+		}
+		got, err := ioutil.ReadFile(filepath.Join(l.Root, "version"))
+		if err != nil {
+			t.Fatalf("reading installed version: %v", err)
+		}
+		if string(got) != version {
+			t.Errorf("InstallVersion(%q) installed version %q", version, got)
+		}
+	}
+
+	// Misses install each version, a hit reuses the cached installation.
+	install("12.18.3")
+	install("14.8.0")
+	install("12.18.3")
+	if want := []string{"12.18.3", "14.8.0"}; !reflect.DeepEqual(installed, want) {
+		t.Errorf("installed %v, want %v", installed, want)
+	}
+
+	// The cached installation is copied, not linked, so changes to the layer do not affect the cache.
+	if err := ioutil.WriteFile(filepath.Join(l.Root, "version"), []byte("modified"), 0644); err != nil {
+		t.Fatalf("modifying layer: %v", err)
+	}
+	install("12.18.3")
+	if len(installed) != 2 {
+		t.Errorf("installed %v after modifying the layer, want no new installation", installed)
+	}
+
+	// The least recently used version is evicted once more than cachedVersions are used.
+	for i := 0; i < cachedVersions; i++ {
+		install(fmt.Sprintf("15.%d.0", i))
+	}
+	cache := filepath.Join(layersDir, "node-versions")
+	if _, err := os.Stat(filepath.Join(cache, "14.8.0")); !os.IsNotExist(err) {
+		t.Errorf("evicted version 14.8.0 still cached (err=%v)", err)
+	}
+	install("14.8.0")
+	if got := installed[len(installed)-1]; got != "14.8.0" {
+		t.Errorf("last installed %q, want evicted version 14.8.0 to be reinstalled", got)
+	}
+}
+
+func TestInstallVersionError(t *testing.T) {
+	layersDir, err := ioutil.TempDir("", "layers-")
+	if err != nil {
+		t.Fatalf("creating temp dir: %v", err)
+	}
+	defer os.RemoveAll(layersDir)
+	ctx := gcp.NewContextForTestsWithLayers(buildpack.Info{ID: "id", Version: "version", Name: "name"}, layersDir, layersDir)
+	l := ctx.Layer("node")
+
+	if err := InstallVersion(ctx, l, "12.18.3", func(dir string) error {
+		return fmt.Errorf("download failed")
+	}); err == nil {
+		t.Fatal("InstallVersion() got no error, want error")
+	}
+	if _, err := os.Stat(filepath.Join(layersDir, "node-versions", "12.18.3")); !os.IsNotExist(err) {
+		t.Errorf("failed installation still cached (err=%v)", err)
+	}
+}
@@ -0,0 +1,287 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"strings"
+
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+)
+
+// Runtimes with a version index.
+const (
+	Nodejs    = "nodejs"
+	Go        = "go"
+	Python    = "python"
+	DotnetSDK = "dotnet"
+	Ruby      = "ruby"
+	PHP       = "php"
+	// DotnetSDKEOL also lists the SDKs of .NET Core channels that have reached end of life.
+	DotnetSDKEOL = "dotnet-eol"
+)
+
+const (
+	nodejsIndexURL = "https://nodejs.org/dist/index.json"
+	goIndexURL     = "https://golang.org/dl/?mode=json&include=all"
+	// pythonIndexURL, rubyIndexURL and phpIndexURL list the Python, Ruby and PHP archives hosted for the buildpacks.
+	pythonIndexURL         = "https://storage.googleapis.com/gcp-buildpacks?prefix=python/python-"
+	rubyIndexURL           = "https://storage.googleapis.com/gcp-buildpacks?prefix=ruby/ruby-"
+	phpIndexURL            = "https://storage.googleapis.com/gcp-buildpacks?prefix=php/php-"
+	dotnetReleasesIndexURL = "https://dotnetcli.blob.core.windows.net/dotnet/release-metadata/releases-index.json"
+)
+
+var (
+	displayNames = map[string]string{
+		Nodejs:       "Node.js",
+		Go:           "Go",
+		Python:       "Python",
+		DotnetSDK:    ".NET Core SDK",
+		DotnetSDKEOL: ".NET Core SDK",
+		Ruby:         "Ruby",
+		PHP:          "PHP",
+	}
+
+	indexes = map[string]VersionIndex{
+		Nodejs:       urlIndex{url: nodejsIndexURL, parse: parseNodejsReleases},
+		Go:           urlIndex{url: goIndexURL, parse: parseGoReleases},
+		Python:       urlIndex{url: pythonIndexURL, parse: archiveReleases(Python)},
+		DotnetSDK:    dotnetIndex{},
+		DotnetSDKEOL: dotnetIndex{eol: true},
+		Ruby:         urlIndex{url: rubyIndexURL, parse: archiveReleases(Ruby)},
+		PHP:          urlIndex{url: phpIndexURL, parse: archiveReleases(PHP)},
+	}
+)
+
+// Release is a version of a runtime that is available for installation.
+type Release struct {
+	// Version is the version as used to download the runtime, e.g. `1.14` for Go's go1.14 release.
+	Version string
+	// LTS is the long-term support codename or phase of the release line, empty if it is not supported long-term.
+	LTS string
+}
+
+// VersionIndex lists the available releases of a runtime.
+type VersionIndex interface {
+	Releases(ctx *gcp.Context) ([]Release, error)
+}
+
+// StaticIndex is a fixed list of releases, used in place of remote indexes in tests.
+type StaticIndex []Release
+
+// Releases returns the releases in the index.
+func (s StaticIndex) Releases(*gcp.Context) ([]Release, error) {
+	return s, nil
+}
+
+// RegisterIndex replaces the version index of runtime and returns a function restoring the previous one.
+// It is used to substitute a StaticIndex for a remote index in tests.
+func RegisterIndex(runtime string, idx VersionIndex) func() {
+	old, ok := indexes[runtime]
+	indexes[runtime] = idx
+	return func() {
+		if ok {
+			indexes[runtime] = old
+		} else {
+			delete(indexes, runtime)
+		}
+	}
+}
+
+func displayName(runtime string) string {
+	if n, ok := displayNames[runtime]; ok {
+		return n
+	}
+	return runtime
+}
+
+// urlIndex is an index fetched from a single URL.
+type urlIndex struct {
+	url   string
+	parse func([]byte) ([]Release, error)
+}
+
+// Releases fetches and parses the index.
+func (i urlIndex) Releases(ctx *gcp.Context) ([]Release, error) {
+	body, err := fetch(ctx, i.url)
+	if err != nil {
+		return nil, err
+	}
+	return i.parse(body)
+}
+
+func fetch(ctx *gcp.Context, url string) ([]byte, error) {
+	result, err := ctx.ExecWithErr([]string{"curl", "--fail", "--show-error", "--silent", "--location", "--retry", "3", url})
+	if err != nil {
+		stderr := ""
+		if result != nil {
+			stderr = result.Stderr
+		}
+		return nil, fmt.Errorf("fetching %s: %v %s", url, err, stderr)
+	}
+	return []byte(result.Stdout), nil
+}
+
+// parseNodejsReleases parses https://nodejs.org/dist/index.json.
+func parseNodejsReleases(body []byte) ([]Release, error) {
+	var entries []struct {
+		Version string `json:"version"`
+		// LTS is either false or the codename of the LTS line, e.g. "Fermium".
+		LTS interface{} `json:"lts"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("parsing Node.js releases from %s: %v", nodejsIndexURL, err)
+	}
+	var releases []Release
+	for _, e := range entries {
+		r := Release{Version: strings.TrimPrefix(e.Version, "v")}
+		if codename, ok := e.LTS.(string); ok {
+			r.LTS = strings.ToLower(codename)
+		}
+		releases = append(releases, r)
+	}
+	return releases, nil
+}
+
+// parseGoReleases parses https://golang.org/dl/?mode=json, skipping unstable releases.
+func parseGoReleases(body []byte) ([]Release, error) {
+	var entries []struct {
+		Version string `json:"version"`
+		Stable  bool   `json:"stable"`
+	}
+	if err := json.Unmarshal(body, &entries); err != nil {
+		return nil, fmt.Errorf("parsing Go releases from %s: %v", goIndexURL, err)
+	}
+	var releases []Release
+	for _, e := range entries {
+		if !e.Stable {
+			continue
+		}
+		if v := strings.TrimPrefix(e.Version, "go"); v != "" {
+			releases = append(releases, Release{Version: v})
+		}
+	}
+	return releases, nil
+}
+
+// archiveReleases returns a parser for the Cloud Storage listing of a runtime's archives, named
+// <runtime>-<version>.tar.gz, e.g. python-3.8.5.tar.gz.
+func archiveReleases(runtime string) func([]byte) ([]Release, error) {
+	prefix := runtime + "-"
+	return func(body []byte) ([]Release, error) {
+		var listing struct {
+			Contents []struct {
+				Key string `xml:"Key"`
+			} `xml:"Contents"`
+		}
+		if err := xml.Unmarshal(body, &listing); err != nil {
+			return nil, fmt.Errorf("parsing %s releases: %v", displayName(runtime), err)
+		}
+		var releases []Release
+		for _, c := range listing.Contents {
+			name := c.Key[strings.LastIndex(c.Key, "/")+1:]
+			if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ".tar.gz") {
+				continue
+			}
+			releases = append(releases, Release{Version: strings.TrimSuffix(strings.TrimPrefix(name, prefix), ".tar.gz")})
+		}
+		return releases, nil
+	}
+}
+
+// dotnetChannel is a release channel in the .NET releases index, e.g. 3.1.
+type dotnetChannel struct {
+	Version      string `json:"channel-version"`
+	SupportPhase string `json:"support-phase"`
+	ReleasesURL  string `json:"releases.json"`
+}
+
+// dotnetIndex lists .NET Core SDKs from the releases index of each supported channel, and of end-of-life channels if
+// eol is set.
+type dotnetIndex struct {
+	eol bool
+}
+
+// Releases fetches the releases index and the releases of each listed channel.
+func (i dotnetIndex) Releases(ctx *gcp.Context) ([]Release, error) {
+	body, err := fetch(ctx, dotnetReleasesIndexURL)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := parseDotnetChannels(body)
+	if err != nil {
+		return nil, err
+	}
+	var releases []Release
+	for _, c := range channels {
+		if c.SupportPhase == "eol" && !i.eol {
+			continue
+		}
+		body, err := fetch(ctx, c.ReleasesURL)
+		if err != nil {
+			return nil, err
+		}
+		sdks, err := parseDotnetSDKs(body, c)
+		if err != nil {
+			return nil, err
+		}
+		releases = append(releases, sdks...)
+	}
+	return releases, nil
+}
+
+func parseDotnetChannels(body []byte) ([]dotnetChannel, error) {
+	var index struct {
+		Channels []dotnetChannel `json:"releases-index"`
+	}
+	if err := json.Unmarshal(body, &index); err != nil {
+		return nil, fmt.Errorf("parsing .NET releases index from %s: %v", dotnetReleasesIndexURL, err)
+	}
+	return index.Channels, nil
+}
+
+// parseDotnetSDKs parses the releases.json of a channel, returning every SDK version it lists.
+func parseDotnetSDKs(body []byte, c dotnetChannel) ([]Release, error) {
+	type sdk struct {
+		Version string `json:"version"`
+	}
+	var channel struct {
+		Releases []struct {
+			SDK  sdk   `json:"sdk"`
+			SDKs []sdk `json:"sdks"`
+		} `json:"releases"`
+	}
+	if err := json.Unmarshal(body, &channel); err != nil {
+		return nil, fmt.Errorf("parsing .NET %s releases from %s: %v", c.Version, c.ReleasesURL, err)
+	}
+	lts := ""
+	if c.SupportPhase == "lts" {
+		lts = c.SupportPhase
+	}
+	seen := map[string]bool{}
+	var releases []Release
+	for _, r := range channel.Releases {
+		for _, s := range append([]sdk{r.SDK}, r.SDKs...) {
+			if s.Version == "" || seen[s.Version] {
+				continue
+			}
+			seen[s.Version] = true
+			releases = append(releases, Release{Version: s.Version, LTS: lts})
+		}
+	}
+	return releases, nil
+}
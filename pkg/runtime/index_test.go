@@ -0,0 +1,240 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/buildpack/libbuildpack/buildpack"
+)
+
+func TestResolveVersion(t *testing.T) {
+	defer RegisterIndex("test", StaticIndex{
+		{Version: "3.8.5"},
+		{Version: "3.8.6"},
+		{Version: "3.9.0"},
+	})()
+
+	testCases := []struct {
+		name       string
+		constraint Constraint
+		want       string
+		wantErr    bool
+	}{
+		{
+			name: "latest",
+			want: "3.9.0",
+		},
+		{
+			name:       "partial",
+			constraint: Constraint{Value: "3.8", Source: ".python-version"},
+			want:       "3.8.6",
+		},
+		{
+			name:       "exact version not in index",
+			constraint: Constraint{Value: "3.7.9", Source: env.RuntimeVersion},
+			want:       "3.7.9",
+		},
+		{
+			name:       "exact version with prefix",
+			constraint: Constraint{Value: "v3.8.5", Source: env.RuntimeVersion},
+			want:       "3.8.5",
+		},
+		{
+			name:       "no match",
+			constraint: Constraint{Value: ">=4", Source: env.RuntimeVersion},
+			wantErr:    true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := gcp.NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+
+			got, err := ResolveVersion(ctx, "test", tc.constraint)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("ResolveVersion(%v) = %q, want error", tc.constraint, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ResolveVersion(%v) got error: %v", tc.constraint, err)
+			}
+			if got != tc.want {
+				t.Errorf("ResolveVersion(%v) = %q, want %q", tc.constraint, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestResolveVersionUnknownRuntime(t *testing.T) {
+	ctx := gcp.NewContext(buildpack.Info{ID: "id", Version: "version", Name: "name"})
+	if got, err := ResolveVersion(ctx, "unknown", Constraint{}); err == nil {
+		t.Errorf("ResolveVersion() = %q, want error", got)
+	}
+}
+
+func TestEnvConstraint(t *testing.T) {
+	if err := os.Setenv(env.RuntimeVersion, " 12.x "); err != nil {
+		t.Fatalf("Failed to set env: %v", err)
+	}
+	defer func() {
+		if err := os.Unsetenv(env.RuntimeVersion); err != nil {
+			t.Fatalf("Failed to unset env: %v", err)
+		}
+	}()
+
+	got, ok := EnvConstraint()
+	if want := (Constraint{Value: "12.x", Source: env.RuntimeVersion}); !ok || got != want {
+		t.Errorf("EnvConstraint() = %v, %t, want %v, true", got, ok, want)
+	}
+}
+
+func TestParseNodejsReleases(t *testing.T) {
+	body := `[
+  {"version": "v14.9.0", "lts": false},
+  {"version": "v12.18.3", "lts": "Erbium"}
+]`
+	want := []Release{
+		{Version: "14.9.0"},
+		{Version: "12.18.3", LTS: "erbium"},
+	}
+
+	got, err := parseNodejsReleases([]byte(body))
+	if err != nil {
+		t.Fatalf("parseNodejsReleases() got error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseNodejsReleases() = %v, want %v", got, want)
+	}
+}
+
+func TestParseGoReleases(t *testing.T) {
+	testCases := []struct {
+		name string
+		json string
+		want []Release
+	}{
+		{
+			name: "all_stable",
+			json: `
+[
+ {
+  "version": "go1.13.3",
+  "stable": true
+ },
+ {
+  "version": "go1.12.12",
+  "stable": true
+ }
+]`,
+			want: []Release{{Version: "1.13.3"}, {Version: "1.12.12"}},
+		},
+		{
+			name: "recent_unstable",
+			json: `
+[
+ {
+  "version": "go1.13.3",
+  "stable": false
+ },
+ {
+  "version": "go1.12.12",
+  "stable": true
+ }
+]`,
+			want: []Release{{Version: "1.12.12"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := parseGoReleases([]byte(tc.json))
+			if err != nil {
+				t.Fatalf("parseGoReleases() got error: %v", err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("parseGoReleases() = %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestArchiveReleases(t *testing.T) {
+	testCases := []struct {
+		runtime string
+		body    string
+		want    []Release
+	}{
+		{
+			runtime: Python,
+			body: `<?xml version='1.0' encoding='UTF-8'?>
+<ListBucketResult xmlns="http://doc.s3.amazonaws.com/2006-03-01">
+  <Name>gcp-buildpacks</Name>
+  <Contents><Key>python/python-3.7.9.tar.gz</Key></Contents>
+  <Contents><Key>python/python-3.8.5.tar.gz</Key></Contents>
+  <Contents><Key>python/python-3.8.5.tar.gz.sha256</Key></Contents>
+</ListBucketResult>`,
+			want: []Release{{Version: "3.7.9"}, {Version: "3.8.5"}},
+		},
+		{
+			runtime: Ruby,
+			body: `<?xml version='1.0' encoding='UTF-8'?>
+<ListBucketResult xmlns="http://doc.s3.amazonaws.com/2006-03-01">
+  <Name>gcp-buildpacks</Name>
+  <Contents><Key>ruby/ruby-2.6.6.tar.gz</Key></Contents>
+  <Contents><Key>ruby/ruby-2.7.2.tar.gz</Key></Contents>
+  <Contents><Key>ruby/rubygems-3.1.4.tar.gz</Key></Contents>
+</ListBucketResult>`,
+			want: []Release{{Version: "2.6.6"}, {Version: "2.7.2"}},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.runtime, func(t *testing.T) {
+			got, err := archiveReleases(tc.runtime)([]byte(tc.body))
+			if err != nil {
+				t.Fatalf("archiveReleases(%q) got error: %v", tc.runtime, err)
+			}
+			if !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("archiveReleases(%q) = %v, want %v", tc.runtime, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseDotnetSDKs(t *testing.T) {
+	body := `{
+  "releases": [
+    {"sdk": {"version": "3.1.402"}, "sdks": [{"version": "3.1.402"}, {"version": "3.1.302"}]},
+    {"sdk": {"version": "3.1.401"}}
+  ]
+}`
+	want := []Release{
+		{Version: "3.1.402", LTS: "lts"},
+		{Version: "3.1.302", LTS: "lts"},
+		{Version: "3.1.401", LTS: "lts"},
+	}
+
+	got, err := parseDotnetSDKs([]byte(body), dotnetChannel{Version: "3.1", SupportPhase: "lts"})
+	if err != nil {
+		t.Fatalf("parseDotnetSDKs() got error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("parseDotnetSDKs() = %v, want %v", got, want)
+	}
+}
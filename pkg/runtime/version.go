@@ -0,0 +1,409 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/GoogleCloudPlatform/buildpacks/pkg/env"
+	gcp "github.com/GoogleCloudPlatform/buildpacks/pkg/gcpbuildpack"
+	"github.com/blang/semver"
+)
+
+// Constraint is a version constraint, along with where it came from.
+type Constraint struct {
+	// Value is an exact version, a semver range such as `^12.4`, `>=3.7 <3.9` or `1.14.x`, the alias `lts`,
+	// `lts/<codename>` or `latest`, or empty to select the latest version.
+	Value string
+	// Source describes where the constraint came from, e.g. `GOOGLE_RUNTIME_VERSION` or `package.json`.
+	Source string
+}
+
+// EnvConstraint returns the constraint from GOOGLE_RUNTIME_VERSION, if set.
+func EnvConstraint() (Constraint, bool) {
+	v := strings.TrimSpace(os.Getenv(env.RuntimeVersion))
+	return Constraint{Value: v, Source: env.RuntimeVersion}, v != ""
+}
+
+func (c Constraint) String() string {
+	if c.Value == "" {
+		return "latest"
+	}
+	source := c.Source
+	if source == "" {
+		source = "default"
+	}
+	return fmt.Sprintf("%q from %s", c.Value, source)
+}
+
+// ResolveVersion returns the newest version of the runtime available in its version index that satisfies the constraint.
+// Exact versions, e.g. `12.18.3`, are returned as is without consulting the index.
+func ResolveVersion(ctx *gcp.Context, runtime string, c Constraint) (string, error) {
+	name := displayName(runtime)
+	value := strings.TrimSpace(c.Value)
+	if isExactVersion(value) {
+		v := strings.TrimPrefix(value, "v")
+		ctx.Logf("Using %s version %s from %s", name, v, sourceOf(c))
+		return v, nil
+	}
+
+	ctx.Logf("Resolving %s version %s", name, c)
+	releases, err := Releases(ctx, runtime)
+	if err != nil {
+		return "", err
+	}
+	r, err := match(releases, value)
+	if err != nil {
+		return "", gcp.UserErrorf("resolving %s version %s: %v", name, c, err)
+	}
+	ctx.Logf("Using %s version %s, resolved from %s", name, r.Version, c)
+	return r.Version, nil
+}
+
+// Releases returns the available releases of the runtime, as listed by its version index.
+func Releases(ctx *gcp.Context, runtime string) ([]Release, error) {
+	idx, ok := indexes[runtime]
+	if !ok {
+		return nil, gcp.InternalErrorf("no version index for runtime %q", runtime)
+	}
+	releases, err := idx.Releases(ctx)
+	if err != nil {
+		return nil, gcp.InternalErrorf("listing %s versions: %v", displayName(runtime), err)
+	}
+	return releases, nil
+}
+
+// ResolveFirst resolves the first of constraints, which are in order of precedence, or the latest version if there
+// are none. A warning is logged for each other constraint that the resolved version does not satisfy.
+func ResolveFirst(ctx *gcp.Context, runtime string, constraints []Constraint) (string, error) {
+	var c Constraint
+	if len(constraints) > 0 {
+		c = constraints[0]
+	}
+	version, err := ResolveVersion(ctx, runtime, c)
+	if err != nil {
+		return "", err
+	}
+	for _, o := range constraints {
+		if o.Value == c.Value {
+			continue
+		}
+		if isAlias(o.Value) {
+			// An alias such as `lts/*` cannot be checked against a version, and is not a mismatch.
+			ctx.Debugf("Not checking %s version %s against alias %q from %s.", displayName(runtime), version, o.Value, o.Source)
+			continue
+		}
+		if !Satisfies(version, o.Value) {
+			ctx.Warnf("Ignoring %s version %q from %s, which does not match version %s from %s.", displayName(runtime), o.Value, o.Source, version, sourceOf(c))
+		}
+	}
+	return version, nil
+}
+
+func sourceOf(c Constraint) string {
+	if c.Source == "" {
+		return "default"
+	}
+	return c.Source
+}
+
+// isAlias returns true if v names a release line rather than a range, e.g. `lts/*`, `node` or `latest`.
+func isAlias(v string) bool {
+	switch c := strings.ToLower(strings.TrimSpace(v)); {
+	case c == "latest" || c == "node" || c == "stable" || c == "lts":
+		return true
+	default:
+		return strings.HasPrefix(c, "lts/")
+	}
+}
+
+// isExactVersion returns true if v is a full semantic version, e.g. `1.2.3` or `v1.2.3-rc.1`.
+func isExactVersion(v string) bool {
+	_, err := semver.Parse(strings.TrimPrefix(v, "v"))
+	return err == nil
+}
+
+// release is a Release with its parsed semantic version.
+type release struct {
+	Release
+	version semver.Version
+}
+
+// match returns the newest stable release satisfying constraint.
+func match(releases []Release, constraint string) (Release, error) {
+	var sorted []release
+	for _, r := range releases {
+		v, err := semver.ParseTolerant(r.Version)
+		if err != nil {
+			// Versions such as Go's `1.15beta1` are not semantic versions, and never selected by ranges.
+			continue
+		}
+		sorted = append(sorted, release{Release: r, version: v})
+	}
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].version.GT(sorted[j].version)
+	})
+
+	// An exact match with a version in the index takes precedence, e.g. `1.14` is Go's go1.14 release.
+	for _, r := range releases {
+		if r.Version == strings.TrimPrefix(constraint, "v") {
+			return r, nil
+		}
+	}
+
+	satisfies := func(release) bool { return true }
+	switch c := strings.ToLower(constraint); {
+	case c == "" || c == "latest" || c == "node" || c == "stable":
+	case c == "lts" || c == "lts/*":
+		satisfies = func(r release) bool { return r.LTS != "" }
+	case strings.HasPrefix(c, "lts/"):
+		// An LTS line by codename, e.g. `lts/fermium`.
+		codename := strings.TrimPrefix(c, "lts/")
+		satisfies = func(r release) bool { return r.LTS == codename }
+	default:
+		rng, err := ParseRange(constraint)
+		if err != nil {
+			return Release{}, err
+		}
+		satisfies = func(r release) bool { return rng(r.version) }
+	}
+
+	for _, r := range sorted {
+		if len(r.version.Pre) > 0 {
+			continue
+		}
+		if satisfies(r) {
+			return r.Release, nil
+		}
+	}
+	return Release{}, fmt.Errorf("no matching version found among %d available versions", len(sorted))
+}
+
+// Satisfies returns true if version is within the range constraint, and false if it is not or either fails to parse.
+// Aliases such as `lts` cannot be checked without a version index, and are never satisfied.
+func Satisfies(version, constraint string) bool {
+	v, err := semver.ParseTolerant(version)
+	if err != nil {
+		return false
+	}
+	rng, err := ParseRange(constraint)
+	if err != nil {
+		return false
+	}
+	return rng(v)
+}
+
+// ParseRange parses an npm-style semver range (https://docs.npmjs.com/misc/semver#ranges), which covers the
+// wildcard (`1.14.x`), partial (`3.8`), comparison (`>=3.7 <3.9`), tilde (`~1.2.3`), caret (`^12`) and hyphen
+// (`1.2 - 1.4`) ranges used across ecosystems. A partial version matches all versions with that prefix.
+func ParseRange(r string) (semver.Range, error) {
+	var alternatives []string
+	for _, alt := range strings.Split(r, "||") {
+		s, err := convertRange(alt)
+		if err != nil {
+			return nil, fmt.Errorf("parsing range %q: %v", r, err)
+		}
+		alternatives = append(alternatives, s)
+	}
+	rng, err := semver.ParseRange(strings.Join(alternatives, " || "))
+	if err != nil {
+		return nil, fmt.Errorf("parsing range %q: %v", r, err)
+	}
+	return rng, nil
+}
+
+// convertRange converts a range without alternatives to the syntax of github.com/blang/semver.
+func convertRange(r string) (string, error) {
+	fields := strings.Fields(r)
+	if len(fields) == 3 && fields[1] == "-" {
+		lo, err := parsePartial(fields[0])
+		if err != nil {
+			return "", err
+		}
+		hi, err := parsePartial(fields[2])
+		if err != nil {
+			return "", err
+		}
+		upper := "<=" + hi.String()
+		if hi.n < 3 {
+			upper = "<" + hi.next().String()
+		}
+		return fmt.Sprintf(">=%s %s", lo, upper), nil
+	}
+
+	// Join operators separated from their version by spaces, e.g. `>= 1.2`.
+	var comparators []string
+	for i := 0; i < len(fields); i++ {
+		f := fields[i]
+		if strings.Trim(f, "<>=~^") == "" && i+1 < len(fields) {
+			f += fields[i+1]
+			i++
+		}
+		comparators = append(comparators, f)
+	}
+	if len(comparators) == 0 {
+		comparators = []string{"*"}
+	}
+
+	var converted []string
+	for _, c := range comparators {
+		s, err := convertComparator(c)
+		if err != nil {
+			return "", err
+		}
+		converted = append(converted, s)
+	}
+	return strings.Join(converted, " "), nil
+}
+
+func convertComparator(c string) (string, error) {
+	op := ""
+	for _, o := range []string{">=", "<=", "!=", "~>", ">", "<", "=", "^", "~"} {
+		if strings.HasPrefix(c, o) {
+			op = o
+			break
+		}
+	}
+	v := strings.TrimPrefix(c, op)
+	if op != "" && v == "" {
+		return "", fmt.Errorf("missing version after %q", op)
+	}
+	p, err := parsePartial(v)
+	if err != nil {
+		return "", err
+	}
+	all, none := ">=0.0.0", "<0.0.0"
+
+	switch op {
+	case "", "=":
+		switch p.n {
+		case 0:
+			return all, nil
+		case 3:
+			return "=" + p.String(), nil
+		}
+		return fmt.Sprintf(">=%s <%s", p, p.next()), nil
+	case "^":
+		switch {
+		case p.n == 0:
+			return all, nil
+		case p.parts[0] > 0 || p.n == 1:
+			return fmt.Sprintf(">=%s <%d.0.0", p, p.parts[0]+1), nil
+		case p.parts[1] > 0 || p.n == 2:
+			return fmt.Sprintf(">=%s <0.%d.0", p, p.parts[1]+1), nil
+		}
+		return fmt.Sprintf(">=%s <0.0.%d", p, p.parts[2]+1), nil
+	case "~", "~>":
+		switch p.n {
+		case 0:
+			return all, nil
+		case 1:
+			return fmt.Sprintf(">=%s <%d.0.0", p, p.parts[0]+1), nil
+		}
+		return fmt.Sprintf(">=%s <%d.%d.0", p, p.parts[0], p.parts[1]+1), nil
+	case "!=":
+		if p.n != 3 {
+			return "", fmt.Errorf("unsupported exclusion of partial version %q", c)
+		}
+		return "!=" + p.String(), nil
+	case ">=":
+		return ">=" + p.String(), nil
+	case ">":
+		switch p.n {
+		case 0:
+			return none, nil
+		case 3:
+			return ">" + p.String(), nil
+		}
+		return ">=" + p.next().String(), nil
+	case "<":
+		if p.n == 0 {
+			return none, nil
+		}
+		return "<" + p.String(), nil
+	case "<=":
+		switch p.n {
+		case 0:
+			return all, nil
+		case 3:
+			return "<=" + p.String(), nil
+		}
+		return "<" + p.next().String(), nil
+	}
+	return "", fmt.Errorf("unsupported comparator %q", c)
+}
+
+// partial is a version that may be missing its minor and patch components, e.g. `12` or `1.14.x`.
+type partial struct {
+	parts [3]uint64
+	// n is the number of components present.
+	n   int
+	pre string
+}
+
+func parsePartial(s string) (partial, error) {
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	var p partial
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		p.pre = s[i:]
+		s = s[:i]
+	}
+	if s == "" {
+		return p, nil
+	}
+	for i, c := range strings.Split(s, ".") {
+		if c == "x" || c == "X" || c == "*" {
+			break
+		}
+		if i >= len(p.parts) {
+			return partial{}, fmt.Errorf("invalid version %q", s)
+		}
+		n, err := strconv.ParseUint(c, 10, 64)
+		if err != nil {
+			return partial{}, fmt.Errorf("invalid version %q", s)
+		}
+		p.parts[i] = n
+		p.n = i + 1
+	}
+	return p, nil
+}
+
+// String returns the lowest version matching p.
+func (p partial) String() string {
+	s := fmt.Sprintf("%d.%d.%d", p.parts[0], p.parts[1], p.parts[2])
+	if p.n == 3 {
+		s += p.pre
+	}
+	return s
+}
+
+// next returns the lowest version greater than all versions matching p.
+func (p partial) next() partial {
+	n := partial{n: 3}
+	switch p.n {
+	case 1:
+		n.parts[0] = p.parts[0] + 1
+	case 2:
+		n.parts[0], n.parts[1] = p.parts[0], p.parts[1]+1
+	default:
+		n.parts = p.parts
+		n.parts[2]++
+	}
+	return n
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Google LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"testing"
+
+	"github.com/blang/semver"
+)
+
+func TestParseRange(t *testing.T) {
+	testCases := []struct {
+		rng string
+		in  []string
+		out []string
+	}{
+		{rng: "", in: []string{"0.0.1", "14.0.0"}},
+		{rng: "*", in: []string{"0.0.1", "14.0.0"}},
+		{rng: "12", in: []string{"12.0.0", "12.18.3"}, out: []string{"11.9.9", "13.0.0"}},
+		{rng: "1.14", in: []string{"1.14.0", "1.14.9"}, out: []string{"1.13.15", "1.15.0"}},
+		{rng: "1.14.x", in: []string{"1.14.0", "1.14.9"}, out: []string{"1.15.0"}},
+		{rng: "12.x.x", in: []string{"12.0.0", "12.18.3"}, out: []string{"13.0.0"}},
+		{rng: "v12.18.3", in: []string{"12.18.3"}, out: []string{"12.18.4"}},
+		{rng: "=12.18.3", in: []string{"12.18.3"}, out: []string{"12.18.4"}},
+		{rng: "^12.4", in: []string{"12.4.0", "12.18.3"}, out: []string{"12.3.9", "13.0.0"}},
+		{rng: "^0.2.3", in: []string{"0.2.3", "0.2.9"}, out: []string{"0.3.0"}},
+		{rng: "^0.0.3", in: []string{"0.0.3"}, out: []string{"0.0.4"}},
+		{rng: "~1.2.3", in: []string{"1.2.3", "1.2.9"}, out: []string{"1.3.0"}},
+		{rng: "~1", in: []string{"1.0.0", "1.9.9"}, out: []string{"2.0.0"}},
+		{rng: "~> 2.6", in: []string{"2.6.0", "2.6.6"}, out: []string{"2.7.0"}},
+		{rng: ">=3.7 <3.9", in: []string{"3.7.0", "3.8.5"}, out: []string{"3.6.9", "3.9.0"}},
+		{rng: ">= 10", in: []string{"10.0.0", "14.0.0"}, out: []string{"9.9.9"}},
+		{rng: ">12", in: []string{"13.0.0"}, out: []string{"12.18.3"}},
+		{rng: "<=3.8", in: []string{"3.8.9"}, out: []string{"3.9.0"}},
+		{rng: "1.2 - 1.4", in: []string{"1.2.0", "1.4.9"}, out: []string{"1.1.9", "1.5.0"}},
+		{rng: "1.2.3 - 1.4.5", in: []string{"1.2.3", "1.4.5"}, out: []string{"1.4.6"}},
+		{rng: ">=3.6 !=3.7.0", in: []string{"3.6.0", "3.7.1"}, out: []string{"3.7.0"}},
+		{rng: "3.8.*", in: []string{"3.8.0", "3.8.6"}, out: []string{"3.9.0"}},
+		{rng: "10 || >=14", in: []string{"10.1.0", "14.2.0"}, out: []string{"12.0.0"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.rng, func(t *testing.T) {
+			r, err := ParseRange(tc.rng)
+			if err != nil {
+				t.Fatalf("ParseRange(%q) got error: %v", tc.rng, err)
+			}
+			for _, v := range tc.in {
+				if !r(semver.MustParse(v)) {
+					t.Errorf("ParseRange(%q) does not match %s, want match", tc.rng, v)
+				}
+			}
+			for _, v := range tc.out {
+				if r(semver.MustParse(v)) {
+					t.Errorf("ParseRange(%q) matches %s, want no match", tc.rng, v)
+				}
+			}
+		})
+	}
+}
+
+func TestParseRangeInvalid(t *testing.T) {
+	for _, rng := range []string{"abc", "1.2.3.4", "^a.b", ">=1 <", "!=3.7"} {
+		if _, err := ParseRange(rng); err == nil {
+			t.Errorf("ParseRange(%q) got no error, want error", rng)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	releases := []Release{
+		{Version: "14.9.0"},
+		{Version: "15.0.0-rc.1"},
+		{Version: "12.18.3", LTS: "erbium"},
+		{Version: "12.19.0", LTS: "erbium"},
+		{Version: "10.22.1", LTS: "dubnium"},
+		{Version: "1.15beta1"},
+		{Version: "1.14"},
+	}
+	testCases := []struct {
+		constraint string
+		want       string
+		wantErr    bool
+	}{
+		{constraint: "", want: "14.9.0"},
+		{constraint: "latest", want: "14.9.0"},
+		{constraint: "lts", want: "12.19.0"},
+		{constraint: "lts/*", want: "12.19.0"},
+		{constraint: "lts/dubnium", want: "10.22.1"},
+		{constraint: "lts/Erbium", want: "12.19.0"},
+		{constraint: "lts/argon", wantErr: true},
+		{constraint: "node", want: "14.9.0"},
+		{constraint: "12.x", want: "12.19.0"},
+		{constraint: "^10 || ^12", want: "12.19.0"},
+		{constraint: "<12", want: "10.22.1"},
+		{constraint: ">=15", wantErr: true},
+		{constraint: "1.14", want: "1.14"},
+		{constraint: "1.15beta1", want: "1.15beta1"},
+		{constraint: "not a range", wantErr: true},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.constraint, func(t *testing.T) {
+			got, err := match(releases, tc.constraint)
+			if tc.wantErr {
+				if err == nil {
+					t.Errorf("match(%q) = %q, want error", tc.constraint, got.Version)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("match(%q) got error: %v", tc.constraint, err)
+			}
+			if got.Version != tc.want {
+				t.Errorf("match(%q) = %q, want %q", tc.constraint, got.Version, tc.want)
+			}
+		})
+	}
+}
+
+func TestSatisfies(t *testing.T) {
+	testCases := []struct {
+		version    string
+		constraint string
+		want       bool
+	}{
+		{version: "12.18.3", constraint: "12.x", want: true},
+		{version: "12.18.3", constraint: ">=14", want: false},
+		{version: "12.18.3", constraint: "v12.18.3", want: true},
+		{version: "12.18.3", constraint: "lts/*", want: false},
+		{version: "invalid", constraint: "*", want: false},
+	}
+	for _, tc := range testCases {
+		if got := Satisfies(tc.version, tc.constraint); got != tc.want {
+			t.Errorf("Satisfies(%q, %q) = %t, want %t", tc.version, tc.constraint, got, tc.want)
+		}
+	}
+}
+
+func TestIsAlias(t *testing.T) {
+	testCases := []struct {
+		value string
+		want  bool
+	}{
+		{value: "lts/*", want: true},
+		{value: "lts/fermium", want: true},
+		{value: "LTS", want: true},
+		{value: "node", want: true},
+		{value: "latest", want: true},
+		{value: "stable", want: true},
+		{value: "12.x", want: false},
+		{value: ">=10", want: false},
+		{value: "", want: false},
+	}
+	for _, tc := range testCases {
+		if got := isAlias(tc.value); got != tc.want {
+			t.Errorf("isAlias(%q) = %t, want %t", tc.value, got, tc.want)
+		}
+	}
+}